@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/diff"
+)
+
+// diffCommand is a subcommand printing per-project changes of measures between two snapshots
+const diffCommand = "diff"
+
+// printDiff parses subcommand's arguments and prints changes between old and new snapshots. Every snapshot is
+// a file or an URL of archived snapshot or an URL of metrics endpoint of a running exporter
+func printDiff(args []string) error {
+	fs := flag.NewFlagSet(diffCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s <old snapshot> <new snapshot>\n", os.Args[0], diffCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("two snapshots are required")
+	}
+
+	old, err := diff.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	current, err := diff.Load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	return diff.Write(os.Stdout, old, current)
+}
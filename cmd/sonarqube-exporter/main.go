@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/accesslog"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/config"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/leader"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/notify"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/update"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+var (
+	gitRevision = "HEAD"
+	buildDate   = "unknown"
+	version     = "unknown"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == generateRulesCommand {
+		if err := generateRules(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == diffCommand {
+		if err := printDiff(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == summaryCommand {
+		if err := printSummary(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cfg, err := config.Parse(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Version {
+		fmt.Printf("Version: %s\n", version)
+		fmt.Printf("Git Revision: %s\n", gitRevision)
+		fmt.Printf("UTC Build Date: %s\n", buildDate)
+		os.Exit(0)
+	}
+	if cfg.Help {
+		fs.Usage()
+		os.Exit(0)
+	}
+	if err := cfg.Validate(); err != nil {
+		fs.Usage()
+		log.Fatal(err)
+	}
+	if cfg.Service != "" {
+		if err := controlService(cfg.Service, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	effective := config.NewEffective(fs, cfg)
+	log.Printf("Effective configuration: %s", effective)
+
+	collectorCfg := exporter.Config{
+		ScrapeTimeout: cfg.ScrapeTimeout,
+		InitialDelay:  cfg.InitialDelay,
+		Concurrency:   cfg.Concurrency,
+		OnError:       exporter.ErrorPolicy(cfg.OnError),
+		MaxFailures:   cfg.MaxFailures,
+		ShardCount:    cfg.ShardCount,
+		ShardIndex:    cfg.ShardIndex,
+		OptOutTag:     cfg.OptOutTag,
+		ProjectFilter: cfg.ProjectFilter,
+		ProjectSort:   cfg.ProjectSort,
+		FileSDPath:    cfg.FileSDPath,
+		FileSDTarget:  cfg.FileSDTarget,
+		ReportPath:    cfg.ReportCSV,
+
+		MaxInterval:         cfg.MaxInterval,
+		LatencyThreshold:    cfg.PressureLatency,
+		ErrorRatioThreshold: cfg.PressureErrorRatio,
+		MinSuccessRatio:     cfg.MinSuccessRatio,
+		RequestBudget:       cfg.RequestBudget,
+		CycleDeadline:       cfg.CycleDeadline,
+
+		ServerInfo:         cfg.ServerInfo,
+		ProjectLinks:       cfg.ProjectLinks,
+		PullRequests:       cfg.PullRequests,
+		GateConditions:     cfg.GateConditions,
+		GateInfo:           cfg.GateInfo,
+		Portfolios:         cfg.Portfolios,
+		CustomMeasures:     cfg.CustomMeasures,
+		CheckTokens:        cfg.CheckTokens,
+		TokenName:          cfg.TokenName,
+		TokenExpiryWarning: cfg.TokenExpiryWarning,
+		ResolvedIssues:     cfg.ResolvedIssues,
+		TopRules:           cfg.TopRules,
+		RefreshToken:       cfg.RefreshToken,
+		Merges:             cfg.File.Merge,
+	}
+	// buckets are validated already
+	collectorCfg.IssueAgeBuckets, _ = cfg.AgeBuckets()
+	// metrics are validated already
+	collectorCfg.Metrics, _ = cfg.Metrics()
+	// priority is validated already
+	collectorCfg.ProjectPriority, _ = exporter.ParseProjectPriority(cfg.ProjectPriority)
+	// quiet hours are validated already
+	collectorCfg.QuietHours, _ = cfg.QuietWindow()
+	// bucket is validated already
+	if bucket, _ := cfg.SnapshotBucket(); bucket != nil {
+		collectorCfg.Archive = bucket.Archive
+	}
+	if bucket, _ := cfg.ReportBucket(); bucket != nil {
+		collectorCfg.ReportUpload = bucket.Archive
+	}
+	// recordings lack responses of probes
+	collectorCfg.Preflight = cfg.Preflight && cfg.ReplayDir == ""
+	if cfg.NotifyWebhook != "" {
+		hostname, _ := os.Hostname()
+		webhook := notify.NewWebhook(cfg.NotifyWebhook, fmt.Sprintf("[%s %s] ", serviceName, hostname))
+		collectorCfg.Notify = webhook.Notify
+		collectorCfg.NotifyThreshold = cfg.NotifyThreshold
+	}
+	// external URL is validated already
+	externalURL, _ := cfg.ExternalURL()
+	if cfg.FileSDPath != "" && cfg.FileSDTarget == "" {
+		if externalURL != nil {
+			collectorCfg.FileSDTarget = externalURL.Host
+		} else {
+			hostname, err := os.Hostname()
+			if err != nil {
+				log.Fatal(err)
+			}
+			collectorCfg.FileSDTarget = net.JoinHostPort(hostname, strconv.Itoa(cfg.Port))
+		}
+	}
+	if externalURL != nil {
+		collectorCfg.FileSDScheme = externalURL.Scheme
+		collectorCfg.FileSDMetricsPath = strings.TrimRight(externalURL.Path, "/") + "/metrics"
+	} else if prefix := cfg.RoutePrefix(); prefix != "" {
+		collectorCfg.FileSDMetricsPath = prefix + "/metrics"
+	}
+
+	// single cycle of 'once' mode collects all projects
+	metricTTL := cfg.MetricTTL
+	if !cfg.Once {
+		collectorCfg.Slices = cfg.CollectSlices
+		// projects are reported once per slices cycles, so TTL is counted in full rounds
+		metricTTL *= cfg.CollectSlices
+	}
+
+	var elector *leader.Elector
+	if cfg.LeaderElect && !cfg.Once {
+		identity, err := os.Hostname()
+		if err != nil {
+			log.Fatal(err)
+		}
+		elector, err = leader.NewInCluster(cfg.LeaderElectNamespace, cfg.LeaderElectLeaseName, identity, cfg.LeaderElectLeaseDuration)
+		if err != nil {
+			log.Fatal(err)
+		}
+		collectorCfg.Leader = elector.IsLeader
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// limits are validated already
+	labelLimits, _ := cfg.LabelLimits()
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{
+		LabelSeparator: cfg.LabelSeparator,
+		LegacyNames:    cfg.LegacyNames,
+		Conversions:    cfg.Conversions(),
+		MetricTTL:      metricTTL,
+		MaxStaleness:   cfg.MaxStaleness,
+		LabelLimits:    labelLimits,
+		LabelPolicies:  cfg.File.Labels,
+		Aliases:        cfg.File.Aliases,
+	})
+	collector := exporter.NewCollector(client, exp, collectorCfg)
+	rollup := exporter.NewRollup(exp)
+
+	if cfg.Once {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(exp, rollup, collector)
+		if err := collector.RunOnce(); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeMetrics(cfg.OnceOutput, reg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	exposition := exporter.NewExposition()
+	prometheus.MustRegister(exp, rollup, collector, exposition)
+	var checker *update.Checker
+	if cfg.UpdateCheck {
+		checker = update.NewChecker(version)
+		prometheus.MustRegister(checker)
+	}
+
+	runService(func(done <-chan struct{}) {
+		m := http.NewServeMux()
+		m.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			exposition.Handler(prometheus.DefaultGatherer)))
+		m.Handle(exporter.RefreshPath, collector.RefreshHandler())
+		m.Handle(exporter.ReadyPath, collector.ReadyHandler())
+		m.Handle(exporter.SARIFPath, collector.SARIFHandler())
+		m.Handle("/debug/config", effective)
+		m.Handle(exporter.MetricsConfigPath, exp.MetricsConfigHandler())
+		var handler http.Handler = m
+		if prefix := cfg.RoutePrefix(); prefix != "" {
+			routed := http.NewServeMux()
+			routed.Handle(prefix+"/", http.StripPrefix(prefix, m))
+			handler = routed
+		}
+		if cfg.AccessLog {
+			handler = accesslog.Handler(handler)
+		}
+		server := &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Port),
+			Handler:           handler,
+			ReadTimeout:       cfg.HTTPReadTimeout,
+			ReadHeaderTimeout: cfg.HTTPReadTimeout,
+			WriteTimeout:      cfg.HTTPWriteTimeout,
+			IdleTimeout:       cfg.HTTPIdleTimeout,
+			MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+
+		if elector != nil {
+			go elector.Run(done)
+		}
+		if checker != nil {
+			go checker.Run(done)
+		}
+		go func() {
+			if err := collector.Run(done); err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		// Waiting for shutdown signal or service stop request
+		<-done
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// writeMetrics writes gathered metrics in text exposition format to file. Dash means stdout
+func writeMetrics(path string, g prometheus.Gatherer) error {
+	if path != "-" {
+		return prometheus.WriteToTextfile(path, g)
+	}
+
+	mfs, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("unable to gather metrics: %w", err)
+	}
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(os.Stdout, mf); err != nil {
+			return fmt.Errorf("unable to write metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// sonarRetryBackoff is a delay before the first retry of failed Sonar requests
+const sonarRetryBackoff = time.Second
+
+// newClient creates Sonar client of validated configuration
+func newClient(cfg *config.Config) (*sonar.Client, error) {
+	rt, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	urls := strings.Split(cfg.SonarURL, ",")
+	client := sonar.NewClient(urls[0], cfg.SonarUser, cfg.SonarPassword,
+		sonar.WithUserAgent(fmt.Sprintf("%s/%s", sonar.DefaultUserAgent, version)),
+		sonar.WithTransport(rt),
+		sonar.WithTimeout(cfg.SonarTimeout),
+		sonar.WithRetry(cfg.SonarRetries, sonarRetryBackoff),
+		sonar.WithBasePath(cfg.SonarBasePath))
+	client.SetFailoverURLs(urls[1:]...)
+	// headers are validated already
+	headers, _ := cfg.Headers()
+	client.SetHeaders(headers)
+	if cfg.HasCredentialFiles() {
+		client.SetCredentialsReload(cfg.LoadCredentials)
+	}
+	if cfg.SonarRateLimit > 0 {
+		client.SetRateLimiter(sonar.NewRateLimiter(cfg.SonarRateLimit, cfg.SonarRateCoreShare))
+	}
+	return client, nil
+}
+
+// newTransport creates transport of Sonar requests, which records Sonar responses or serves recorded ones if requested
+func newTransport(cfg *config.Config) (http.RoundTripper, error) {
+	if cfg.ReplayDir != "" {
+		return sonar.NewReplayTransport(cfg.ReplayDir)
+	}
+
+	// proxy is validated already
+	proxy, _ := cfg.Proxy()
+	// hosts and DNS server are validated already
+	hosts, _ := cfg.Hosts()
+	dnsServer, _ := cfg.DNSServer()
+	// SSH jump host is validated already
+	jumpHost, _ := cfg.SSH()
+	transport, err := sonar.NewTransport(sonar.TransportConfig{
+		HTTPVersion:     cfg.SonarHTTPVersion,
+		TLSSessionCache: cfg.SonarTLSSessionCache,
+		Proxy:           proxy,
+		Hosts:           hosts,
+		DNSServer:       dnsServer,
+		SSH:             jumpHost,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SonarReresolveInterval > 0 {
+		// connections are closed for the whole lifetime of the process
+		go sonar.CloseIdleConnections(transport, cfg.SonarReresolveInterval, nil)
+	}
+	var rt http.RoundTripper = transport
+	// OAuth2 is validated already
+	if oauth2, _ := cfg.OAuth2(); oauth2 != nil {
+		rt = sonar.NewOAuth2Transport(*oauth2, rt)
+	}
+	if cfg.RecordDir != "" {
+		return sonar.NewRecordingTransport(cfg.RecordDir, rt)
+	}
+	return rt, nil
+}
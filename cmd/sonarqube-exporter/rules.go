@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/rules"
+)
+
+// generateRulesCommand is a subcommand printing Prometheus rules for exported metrics
+const generateRulesCommand = "generate-rules"
+
+// generateRules parses subcommand's arguments and writes generated rules
+func generateRules(args []string) error {
+	opts := rules.DefaultOptions()
+	var output, labels, metricsURL, rollupLabels string
+
+	fs := flag.NewFlagSet(generateRulesCommand, flag.ExitOnError)
+	fs.StringVar(&output, "output", "-", "File rules are written to. Dash means stdout")
+	fs.StringVar(&opts.Namespace, "namespace", opts.Namespace, "Prefix of exported metric names")
+	fs.StringVar(&opts.Group, "group", opts.Group, "Name of the rule group")
+	fs.StringVar(&opts.Selector, "selector", "", "Label matchers added to every expression, e.g. team=\"core\"")
+	fs.StringVar(&labels, "labels", "severity=warning", "Comma separated key=value labels attached to every alert")
+	fs.Float64Var(&opts.CoverageDrop, "coverage-drop", opts.CoverageDrop,
+		"Drop of coverage in percentage points within coverage window which fires an alert")
+	fs.DurationVar(&opts.CoverageWindow, "coverage-window", opts.CoverageWindow, "Period coverage drop is calculated for")
+	fs.DurationVar(&opts.NoAnalysis, "no-analysis", opts.NoAnalysis, "Period without analysis after which an alert fires")
+	fs.DurationVar(&opts.TokenExpiry, "token-expiry", opts.TokenExpiry, "Time before Sonar token expiration starting from which an alert fires")
+	fs.DurationVar(&opts.For, "for", opts.For, "Duration condition must hold before alerts fire")
+	fs.StringVar(&metricsURL, "metrics-url", "", "Metrics endpoint of running exporter, e.g. http://localhost:8080/metrics. "+
+		"If provided, recording rules pre-aggregating metrics with many series are generated")
+	fs.IntVar(&opts.MinSeries, "min-series", opts.MinSeries, "Number of series starting from which metric is pre-aggregated")
+	fs.StringVar(&rollupLabels, "rollup-labels", "", "Comma separated labels metrics are aggregated by. "+
+		"Defaults to all labels except component ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+	if opts.Labels, err = rules.ParseLabels(labels); err != nil {
+		return err
+	}
+	if rollupLabels != "" {
+		opts.RollupLabels = strings.Split(rollupLabels, ",")
+	}
+	if metricsURL != "" {
+		if opts.Families, err = rules.FetchFamilies(metricsURL); err != nil {
+			return err
+		}
+	}
+	content, err := rules.Generate(opts)
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := ioutil.WriteFile(output, content, 0o644); err != nil {
+		return fmt.Errorf("unable to write rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// runService runs exporter until shutdown signal is received
+func runService(run func(done <-chan struct{})) {
+	runInteractive(run)
+}
+
+// controlService is supported on Windows only, other platforms rely on their service managers
+func controlService(string, []string) error {
+	return fmt.Errorf("service management is not supported on %s", runtime.GOOS)
+}
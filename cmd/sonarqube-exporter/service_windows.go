@@ -0,0 +1,144 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// runService runs exporter as Windows service if started by service manager,
+// otherwise until shutdown signal is received
+func runService(run func(done <-chan struct{})) {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Fatalf("unable to detect Windows service: %v", err)
+	}
+	if !isService {
+		runInteractive(run)
+		return
+	}
+	if err := svc.Run(serviceName, &windowsService{run: run}); err != nil {
+		log.Fatalf("unable to run Windows service: %v", err)
+	}
+}
+
+// windowsService handles Windows service control requests
+type windowsService struct {
+	run func(done <-chan struct{})
+}
+
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		s.run(done)
+		close(finished)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-finished:
+			return false, 0
+		case rq := <-requests:
+			switch rq.Cmd {
+			case svc.Interrogate:
+				changes <- rq.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				close(done)
+				<-finished
+				return false, 0
+			default:
+				log.Printf("Unexpected service control request: %d", rq.Cmd)
+			}
+		}
+	}
+}
+
+// controlService installs, uninstalls, starts or stops Windows service.
+// Service is installed with provided arguments except service control flag
+func controlService(action string, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("unable to connect to service manager: %w", err)
+	}
+	defer func() {
+		if err := m.Disconnect(); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	if action == "install" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("unable to detect executable path: %w", err)
+		}
+		exe, err = filepath.Abs(exe)
+		if err != nil {
+			return fmt.Errorf("unable to detect executable path: %w", err)
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "SonarQube Prometheus Exporter",
+			Description: "Exports SonarQube measures as Prometheus metrics",
+			StartType:   mgr.StartAutomatic,
+		}, serviceArgs(args)...)
+		if err != nil {
+			return fmt.Errorf("unable to install service: %w", err)
+		}
+		return s.Close()
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("unable to open service: %w", err)
+	}
+	defer func() {
+		if err := s.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	switch action {
+	case "uninstall":
+		err = s.Delete()
+	case "start":
+		err = s.Start()
+	case "stop":
+		_, err = s.Control(svc.Stop)
+	default:
+		return fmt.Errorf("unknown service action: %s", action)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s service: %w", action, err)
+	}
+	return nil
+}
+
+// serviceArgs returns command line arguments without service control flag
+func serviceArgs(args []string) []string {
+	var res []string
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if name == "service" {
+			// skip flag value as well
+			i++
+			continue
+		}
+		if strings.HasPrefix(name, "service=") {
+			continue
+		}
+		res = append(res, args[i])
+	}
+	return res
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// serviceName is a name of exporter's system service
+const serviceName = "sonarqube-prometheus-exporter"
+
+// shutdownSignals stop the exporter gracefully. On Windows console close,
+// logoff and shutdown events are delivered as SIGTERM
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// runInteractive runs exporter until shutdown signal is received
+func runInteractive(run func(done <-chan struct{})) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, shutdownSignals...)
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		close(done)
+	}()
+
+	run(done)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/config"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/summary"
+)
+
+// summaryCommand is a subcommand printing quality summary of a project
+const summaryCommand = "summary"
+
+// printSummary parses subcommand's arguments and prints summary of the project. Sonar connection is
+// configured with the same flags, environment variables and configuration file as the exporter itself
+func printSummary(args []string) error {
+	var project string
+	fs := flag.NewFlagSet(summaryCommand, flag.ExitOnError)
+	fs.StringVar(&project, "project", "", "Required. Key of the project")
+	cfg, err := config.Parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if project == "" {
+		fs.Usage()
+		return errors.New("project is required")
+	}
+	if err := cfg.Validate(); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	s, err := summary.Load(client, project)
+	if err != nil {
+		return err
+	}
+	return s.Write(os.Stdout)
+}
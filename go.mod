@@ -4,4 +4,9 @@ go 1.16
 
 require (
 	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.18.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/sys v0.0.0-20210309074719-68d13333faf2
+	gopkg.in/yaml.v2 v2.3.0
 )
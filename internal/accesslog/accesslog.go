@@ -0,0 +1,37 @@
+// Package accesslog logs requests served by the exporter
+package accesslog
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// Handler logs method, path, status, size, duration and remote address of every request served by next handler
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, rq)
+		log.Printf("Access method=%s path=%s status=%d bytes=%d duration=%s remote=%s",
+			rq.Method, rq.URL.Path, rw.status, rw.written, time.Since(start), rq.RemoteAddr)
+	})
+}
+
+// responseWriter records status code and size of the response
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += n
+	return n, err
+}
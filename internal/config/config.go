@@ -0,0 +1,696 @@
+// Package config contains exporter's command line configuration
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/internal/objstore"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// Config is an exporter configuration
+type Config struct {
+	ConfigFile     string
+	Port           int
+	ScrapeTimeout  time.Duration
+	InitialDelay   time.Duration
+	Once           bool
+	OnceOutput     string
+	Concurrency    int
+	RequestBudget  int
+	OnError        string
+	MaxFailures    int
+	SonarURL       string
+	SonarUser      string
+	SonarPassword  string
+	LabelSeparator string
+	MaxLabelValues string
+	MetricTTL      int
+	MaxStaleness   time.Duration
+	OptOutTag      string
+	MetricsPreset  string
+	ProjectFilter  string
+	ProjectSort    string
+	RecordDir      string
+	ReplayDir      string
+	ShardIndex     int
+	ShardCount     int
+	CollectSlices  int
+	FileSDPath     string
+	FileSDTarget   string
+	ReportCSV      string
+	ReportURL      string
+
+	SnapshotURL       string
+	SnapshotRegion    string
+	SnapshotAccessKey string
+	SnapshotSecretKey string
+	SnapshotRetention time.Duration
+
+	MaxInterval        time.Duration
+	CycleDeadline      time.Duration
+	QuietHours         string
+	QuietHoursTimezone string
+	PressureLatency    time.Duration
+	PressureErrorRatio float64
+	MinSuccessRatio    float64
+	ProjectPriority    string
+
+	SonarUserFile     string
+	SonarPasswordFile string
+
+	SonarTimeout           time.Duration
+	SonarRetries           int
+	SonarBasePath          string
+	SonarHTTPVersion       string
+	SonarTLSSessionCache   int
+	SonarReresolveInterval time.Duration
+	SonarProxy             string
+	SonarHeaders           stringList
+	SonarHosts             stringList
+	SonarDNSServer         string
+	SonarSSHHost           string
+	SonarSSHUser           string
+	SonarSSHKey            string
+	SonarSSHKnownHosts     string
+	SonarRateLimit         float64
+	SonarRateCoreShare     float64
+
+	OAuth2TokenURL     string
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2Scopes       string
+	OAuth2Header       string
+
+	NotifyWebhook   string
+	NotifyThreshold int
+
+	IssueAgeBuckets string
+	ResolvedIssues  bool
+	TopRules        int
+	RefreshToken    string
+
+	HTTPReadTimeout    time.Duration
+	HTTPWriteTimeout   time.Duration
+	HTTPIdleTimeout    time.Duration
+	HTTPMaxHeaderBytes int
+	AccessLog          bool
+	WebRoutePrefix     string
+	WebExternalURL     string
+
+	ServerInfo     bool
+	ProjectLinks   bool
+	PullRequests   bool
+	GateConditions bool
+	GateInfo       bool
+	Portfolios     bool
+	Preflight      bool
+	CustomMeasures bool
+	UpdateCheck    bool
+	LegacyNames    bool
+
+	CheckTokens        bool
+	TokenName          string
+	TokenExpiryWarning time.Duration
+
+	LeaderElect              bool
+	LeaderElectNamespace     string
+	LeaderElectLeaseName     string
+	LeaderElectLeaseDuration time.Duration
+
+	Service string
+	Version bool
+	Help    bool
+
+	// File is a content of configuration file. Empty if no file provided
+	File *File
+	// envFlags are names of flags set from environment variables
+	envFlags map[string]struct{}
+}
+
+// Parse registers flags on provided flag set and parses arguments.
+// Every flag may be provided with environment variable as well, see EnvName
+func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := &Config{}
+
+	fs.StringVar(&cfg.ConfigFile, "config", "", "Path to YAML configuration file")
+	fs.IntVar(&cfg.Port, "port", 8080, "Exporter port")
+	fs.DurationVar(&cfg.ScrapeTimeout, "scrape-timeout", 1*time.Minute, "Metrics scraper timeout")
+	fs.DurationVar(&cfg.MaxInterval, "max-interval", 0, "Max delay between collection cycles scrape-timeout is stretched to "+
+		"while Sonar is under pressure. Zero disables adaptive scheduling")
+	fs.DurationVar(&cfg.PressureLatency, "pressure-latency", 2*time.Second, "Mean latency of Sonar API calls of a cycle "+
+		"above which Sonar is considered under pressure")
+	fs.Float64Var(&cfg.PressureErrorRatio, "pressure-error-ratio", 0.1, "Ratio of failed Sonar API calls of a cycle "+
+		"above which Sonar is considered under pressure")
+	fs.DurationVar(&cfg.CycleDeadline, "cycle-deadline", 0, "Max duration of a collection cycle. Once passed, projects not "+
+		"collected yet are left for the next cycle, which collects them first. Zero means no limit")
+	fs.StringVar(&cfg.QuietHours, "quiet-hours", "", "Daily window collection is paused during while cached measures are "+
+		"exported, e.g. 02:00-03:00 for Sonar's nightly housekeeping. May be preceded by comma separated days the window "+
+		"starts on, e.g. Sat,Sun 02:00-06:00. Empty means no pause")
+	fs.StringVar(&cfg.QuietHoursTimezone, "quiet-hours-timezone", "", "Time zone of quiet hours, e.g. Europe/Berlin. "+
+		"Defaults to local time zone")
+	fs.DurationVar(&cfg.InitialDelay, "initial-delay", 0, "Delay before the first collection cycle")
+	fs.BoolVar(&cfg.Once, "once", false, "Run single collection cycle, write metrics and exit")
+	fs.StringVar(&cfg.OnceOutput, "once-output", "-", "File metrics are written to in 'once' mode. Dash means stdout")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 5, "Max number of projects collected in parallel")
+	fs.Float64Var(&cfg.MinSuccessRatio, "min-success-ratio", 0, "Share of projects which must be collected successfully "+
+		"during the last collection cycle for the exporter to be ready")
+	fs.IntVar(&cfg.RequestBudget, "request-budget", 0, "Max number of Sonar API requests of a collection cycle, retries included. "+
+		"Once spent, the cycle is aborted and the rest of projects keep previous measures. Zero means no limit")
+	fs.StringVar(&cfg.OnError, "on-error", string(exporter.ErrorPolicyContinue),
+		"Behavior on failed collection cycles: continue, exit or backoff")
+	fs.IntVar(&cfg.MaxFailures, "max-failures", 0, "Number of collection cycles failed in a row after which exporter exits. "+
+		"Zero means one failure for 'exit' policy and no limit for 'backoff' policy")
+	fs.StringVar(&cfg.SonarURL, "url", "", "Required. Sonarqube URL. Comma separated failover URLs may follow the primary one")
+	fs.StringVar(&cfg.SonarUser, "user", "", "Required. Sonarqube User")
+	fs.StringVar(&cfg.SonarPassword, "password", "", "Required. Sonarqube Password")
+	fs.StringVar(&cfg.SonarUserFile, "user-file", "", "File Sonarqube User is read from. Reloaded once Sonar rejects credentials")
+	fs.StringVar(&cfg.SonarPasswordFile, "password-file", "", "File Sonarqube Password or token is read from. "+
+		"Reloaded once Sonar rejects credentials")
+	fs.DurationVar(&cfg.SonarTimeout, "sonar-timeout", sonar.DefaultTimeout, "Timeout of every Sonar API request "+
+		"including reading of the response. Zero means no limit")
+	fs.IntVar(&cfg.SonarRetries, "sonar-retries", 0, "Number of retries of Sonar API requests failed due to network errors, "+
+		"throttling or server errors. Retries back off exponentially starting from a second unless Sonar sends Retry-After")
+	fs.StringVar(&cfg.SonarBasePath, "sonar-base-path", "", "Context path Sonar is served under behind a reverse proxy, "+
+		"e.g. /sonar, so -url may point to the proxy itself")
+	fs.StringVar(&cfg.SonarHTTPVersion, "sonar-http-version", "", "HTTP version of Sonar API requests: 1.1 disables HTTP/2, "+
+		"2 attempts HTTP/2 over TLS. Empty keeps defaults")
+	fs.IntVar(&cfg.SonarTLSSessionCache, "sonar-tls-session-cache", 0, "Number of Sonar TLS sessions cached for resumption. "+
+		"Zero disables resumption")
+	fs.DurationVar(&cfg.SonarReresolveInterval, "sonar-reresolve-interval", 0, "Interval idle Sonar connections are closed at, "+
+		"so new ones resolve Sonar host again, e.g. behind a load balancer rotating IPs. Zero disables the closing")
+	fs.Float64Var(&cfg.SonarRateLimit, "sonar-rate-limit", 0, "Max number of Sonar API requests per second shared by all collectors. "+
+		"Zero means no limit")
+	fs.Float64Var(&cfg.SonarRateCoreShare, "sonar-rate-core-share", 0.8, "Share of -sonar-rate-limit reserved for requests "+
+		"measures are collected with, so optional collectors, e.g. issues, can not starve them")
+	fs.StringVar(&cfg.SonarProxy, "sonar-proxy", "", "URL of HTTP or SOCKS5 proxy Sonar is reached through, "+
+		"e.g. socks5://bastion:1080. Defaults to HTTPS_PROXY and HTTP_PROXY environment variables")
+	fs.Var(&cfg.SonarHeaders, "sonar-header", "Static header sent with every Sonar request, e.g. X-Api-Key=secret. "+
+		"Repeat the flag for several headers or separate them with commas in environment variable")
+	fs.Var(&cfg.SonarHosts, "sonar-host", "Static mapping of Sonar host name to IP address connections are made to instead of "+
+		"resolving the host, e.g. sonar.internal.example.com=10.0.0.5. Repeat the flag for several hosts or separate them with commas in environment variable")
+	fs.StringVar(&cfg.SonarDNSServer, "sonar-dns-server", "", "Address of DNS server Sonar host is resolved with, e.g. 10.0.0.2:53. "+
+		"Empty uses system resolver")
+	fs.StringVar(&cfg.SonarSSHHost, "sonar-ssh-host", "", "SSH jump host connections to Sonar are tunneled through, "+
+		"e.g. bastion.example.com:22. Port defaults to 22. Sonar host is resolved by the jump host")
+	fs.StringVar(&cfg.SonarSSHUser, "sonar-ssh-user", "", "User of SSH jump host")
+	fs.StringVar(&cfg.SonarSSHKey, "sonar-ssh-key", "", "File of unencrypted private key SSH jump host user is authenticated with")
+	fs.StringVar(&cfg.SonarSSHKnownHosts, "sonar-ssh-known-hosts", "", "File of known host keys SSH jump host is verified against. "+
+		"Empty means ~/.ssh/known_hosts")
+	fs.StringVar(&cfg.OAuth2TokenURL, "oauth2-token-url", "", "Token URL of OAuth2 gateway Sonar is fronted with. "+
+		"Bearer token is obtained with client credentials flow and sent with every Sonar request")
+	fs.StringVar(&cfg.OAuth2ClientID, "oauth2-client-id", "", "OAuth2 client ID")
+	fs.StringVar(&cfg.OAuth2ClientSecret, "oauth2-client-secret", "", "OAuth2 client secret")
+	fs.StringVar(&cfg.OAuth2Scopes, "oauth2-scopes", "", "Comma separated OAuth2 scopes requested")
+	fs.StringVar(&cfg.OAuth2Header, "oauth2-header", "Authorization", "Request header bearer token is sent in. "+
+		"Authorization replaces Sonar credentials, which are not required then")
+	fs.StringVar(&cfg.LabelSeparator, "label-separator", "#", "Label Separator. For instance, "+
+		"for Sonar with Label 'key#value', Prometheus attribute {project=\"my-project-name\"}")
+	fs.BoolVar(&cfg.LegacyNames, "legacy-metric-names", false, "Name series after their projects, e.g. "+
+		"sonar_my_project_coverage, as exporter did before per-project series got component label")
+	fs.StringVar(&cfg.MaxLabelValues, "max-label-values", "", "Comma separated max numbers of distinct values of labels converted "+
+		"from tags, e.g. team=50. Values beyond the limit are replaced with 'other'")
+	fs.IntVar(&cfg.MetricTTL, "metric-ttl", 3, "Number of collection cycles series are kept for after they were reported last time, "+
+		"e.g. while their project fails to be collected. Zero keeps series until the project is deleted")
+	fs.DurationVar(&cfg.MaxStaleness, "max-staleness", 0, "Stop exposing measures of a project collected earlier than that. "+
+		"Zero disables the check")
+	fs.StringVar(&cfg.MetricsPreset, "metrics-preset", "", "Curated set of collected metrics: "+
+		strings.Join(exporter.MetricPresets(), ", ")+". Empty collects all metrics of Sonar")
+	fs.StringVar(&cfg.OptOutTag, "opt-out-tag", "prometheus#skip", "Sonar tag excluding tagged projects from export, "+
+		"so project owners may opt out themselves. Empty disables the opt-out")
+	fs.StringVar(&cfg.ProjectFilter, "project-filter", "", "Filter of projects collected in syntax of api/components/search_projects, "+
+		"e.g. 'alert_status = ERROR and ncloc > 1000'. Empty collects all projects")
+	fs.StringVar(&cfg.ProjectSort, "project-sort", "", "Field projects are collected in order of, e.g. ncloc. "+
+		"Prefix with - for descending order")
+	fs.StringVar(&cfg.ProjectPriority, "project-priority", "", "Comma separated priorities projects are collected in order of "+
+		"during a cycle: tag:<tag> puts tagged projects first, recent puts recently analyzed projects first, e.g. tag:critical,recent. "+
+		"Empty keeps order projects are listed in")
+	fs.StringVar(&cfg.ReportCSV, "report-csv", "", "File measures of exported projects are written to as CSV every cycle, "+
+		"e.g. /reports/sonar-{date}.csv. {date} is replaced with the date of the cycle. Empty disables the report")
+	fs.StringVar(&cfg.ReportURL, "report-url", "", "URL of S3 compatible bucket CSV report is uploaded to every cycle as sonar-<date>.csv, "+
+		"e.g. https://s3.eu-west-1.amazonaws.com/bucket/reports/. Region and keys of snapshot bucket are used. Empty disables the upload")
+	fs.StringVar(&cfg.SnapshotURL, "snapshot-url", "", "URL of S3 compatible bucket gzipped JSON snapshots of exported series "+
+		"are uploaded to every cycle, e.g. https://s3.eu-west-1.amazonaws.com/bucket/prefix/. Empty disables snapshots")
+	fs.StringVar(&cfg.SnapshotRegion, "snapshot-region", "us-east-1", "Region of snapshot bucket")
+	fs.StringVar(&cfg.SnapshotAccessKey, "snapshot-access-key", "", "Access key of snapshot bucket")
+	fs.StringVar(&cfg.SnapshotSecretKey, "snapshot-secret-key", "", "Secret key of snapshot bucket")
+	fs.DurationVar(&cfg.SnapshotRetention, "snapshot-retention", 0, "Age snapshots are deleted after. 0 keeps snapshots forever")
+	fs.StringVar(&cfg.RecordDir, "record-dir", "", "Directory Sonar API responses are recorded to")
+	fs.StringVar(&cfg.ReplayDir, "replay-dir", "", "Directory recorded Sonar API responses are served from instead of calling Sonar. "+
+		"Sonar URL and credentials are not required in this mode")
+	fs.IntVar(&cfg.ShardCount, "shard-count", 1, "Number of exporter instances projects are split between")
+	fs.IntVar(&cfg.ShardIndex, "shard-index", 0, "Index of projects shard collected by this instance, from 0 to shard-count - 1")
+	fs.IntVar(&cfg.CollectSlices, "collect-slices", 1, "Number of collection cycles projects are spread across in round-robin. "+
+		"Every cycle collects 1/N of projects, so each project is refreshed once per N cycles")
+
+	fs.StringVar(&cfg.FileSDPath, "file-sd-output", "", "File collected projects are written to as Prometheus file_sd targets")
+	fs.StringVar(&cfg.FileSDTarget, "file-sd-target", "", "Exporter address written to file_sd targets. Defaults to hostname:port")
+
+	fs.StringVar(&cfg.NotifyWebhook, "notify-webhook", "", "Slack or Microsoft Teams incoming webhook URL collection failures are posted to")
+	fs.IntVar(&cfg.NotifyThreshold, "notify-threshold", 3, "Number of collection cycles failed in a row after which notification is posted. "+
+		"Authentication failures are posted immediately")
+
+	fs.StringVar(&cfg.IssueAgeBuckets, "issue-age-buckets", "", "Comma separated ages in days open issues are counted by, e.g. 7,30,90. "+
+		"Costs an API call per bucket and project every cycle. Empty disables the count")
+	fs.BoolVar(&cfg.ResolvedIssues, "resolved-issues", false, "Count issues resolved as false positive or accepted by severity. "+
+		"Costs two API calls per project every cycle")
+	fs.IntVar(&cfg.TopRules, "top-rules", 0, "Number of rules with the most open issues exported per project. "+
+		"Costs an API call per project every cycle. Zero disables the export")
+	fs.StringVar(&cfg.RefreshToken, "refresh-token", "", "Bearer token required by on-demand refresh of projects. "+
+		"Empty disables the refresh")
+	fs.BoolVar(&cfg.ServerInfo, "server-info", false, "Export version and edition of Sonar server as sonar_server_info. "+
+		"Fetched once on start")
+	fs.BoolVar(&cfg.ProjectLinks, "project-links", false, "Export links of projects, e.g. homepage, CI and SCM, as sonar_project_links. "+
+		"Costs an API call per project every cycle")
+	fs.BoolVar(&cfg.GateConditions, "gate-conditions", false, "Export thresholds and actual values of quality gate conditions "+
+		"as sonar_quality_gate_threshold and sonar_quality_gate_condition_value. Costs an API call per project every cycle")
+	fs.BoolVar(&cfg.GateInfo, "gate-info", false, "Export quality gates assigned to projects as sonar_project_quality_gate_info. "+
+		"Costs an API call per project every cycle")
+	fs.BoolVar(&cfg.PullRequests, "pull-requests", false, "Export number of pull requests failing quality gate by target branch "+
+		"as sonar_pull_requests_failing_quality_gate. Costs an API call per project every cycle. Supported by Developer edition and above")
+	fs.BoolVar(&cfg.Portfolios, "portfolios", false, "Export ratings of portfolios and the worst ratings of their projects by domain. "+
+		"Costs an API call per portfolio every cycle. Supported by Enterprise edition and above")
+	fs.BoolVar(&cfg.Preflight, "preflight", true, "Probe Sonar API endpoints required by enabled features on start "+
+		"and report the ones the user lacks permissions for")
+	fs.BoolVar(&cfg.CustomMeasures, "custom-measures", false, "Export custom (manual) measures not taken into account "+
+		"by analysis yet. Costs an API call per project every cycle. Supported by Sonar prior to 9.0")
+	fs.BoolVar(&cfg.UpdateCheck, "update-check", false, "Check GitHub releases for a newer exporter version daily "+
+		"and export sonar_exporter_update_available. Requests honor HTTPS_PROXY environment variable")
+	fs.BoolVar(&cfg.CheckTokens, "check-token-expiry", false, "Export time left before expiration of Sonar user tokens every collection cycle")
+	fs.StringVar(&cfg.TokenName, "token-name", "", "Name of the token exporter uses. Defaults to all tokens of the user")
+	fs.DurationVar(&cfg.TokenExpiryWarning, "token-expiry-warning", 7*24*time.Hour, "Time before token expiration starting from which warnings are logged")
+
+	fs.DurationVar(&cfg.HTTPReadTimeout, "http-read-timeout", 10*time.Second, "Max duration of reading exporter's HTTP request "+
+		"including body. Zero disables the timeout")
+	fs.DurationVar(&cfg.HTTPWriteTimeout, "http-write-timeout", 2*time.Minute, "Max duration of writing exporter's HTTP response. "+
+		"Should exceed scrape-timeout as on-demand refresh responds once project is collected. Zero disables the timeout")
+	fs.DurationVar(&cfg.HTTPIdleTimeout, "http-idle-timeout", 2*time.Minute, "Max duration keep-alive connection waits for the next request. "+
+		"Zero disables the timeout")
+	fs.IntVar(&cfg.HTTPMaxHeaderBytes, "http-max-header-bytes", 16<<10, "Max size of exporter's HTTP request headers")
+	fs.BoolVar(&cfg.AccessLog, "access-log", false, "Log method, path, status, duration and remote address of every request served")
+	fs.StringVar(&cfg.WebRoutePrefix, "web-route-prefix", "", "Path prefix of exporter's endpoints, e.g. /sonar-exporter "+
+		"serves metrics at /sonar-exporter/metrics. Defaults to path of web-external-url")
+	fs.StringVar(&cfg.WebExternalURL, "web-external-url", "", "URL exporter is reachable at externally, e.g. through a reverse proxy. "+
+		"Used as address of file_sd targets")
+
+	fs.BoolVar(&cfg.LeaderElect, "leader-elect", false, "Enable Kubernetes Lease based leader election. "+
+		"Only the leader collects measures")
+	fs.StringVar(&cfg.LeaderElectNamespace, "leader-elect-namespace", "", "Namespace of the Lease. Defaults to namespace of the pod")
+	fs.StringVar(&cfg.LeaderElectLeaseName, "leader-elect-lease-name", "sonarqube-prometheus-exporter", "Name of the Lease")
+	fs.DurationVar(&cfg.LeaderElectLeaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"Duration followers wait before taking over the Lease")
+
+	fs.StringVar(&cfg.Service, "service", "", "Windows service control action: install, uninstall, start or stop")
+	fs.BoolVar(&cfg.Version, "version", false, "Show version")
+	fs.BoolVar(&cfg.Help, "help", false, "Show help")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	var err error
+	if cfg.envFlags, err = bindEnv(fs); err != nil {
+		return nil, err
+	}
+
+	cfg.File = &File{}
+	if cfg.ConfigFile != "" {
+		f, err := LoadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.File = f
+	}
+	if cfg.HasCredentialFiles() {
+		if cfg.SonarUser, cfg.SonarPassword, err = cfg.LoadCredentials(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Validate makes sure all required options are provided
+func (c *Config) Validate() error {
+	if c.ReplayDir != "" && c.RecordDir != "" {
+		return errors.New("record and replay modes are mutually exclusive")
+	}
+	// gateway authenticates requests itself once bearer token replaces basic auth
+	credentials := c.OAuth2TokenURL != "" && strings.EqualFold(c.OAuth2Header, "Authorization")
+	if c.ReplayDir == "" && (c.SonarURL == "" || !credentials && (c.SonarUser == "" || c.SonarPassword == "")) {
+		return errors.New("make sure all required flags are provided")
+	}
+	if _, err := exporter.ParseErrorPolicy(c.OnError); err != nil {
+		return err
+	}
+	if _, err := exporter.ParseProjectPriority(c.ProjectPriority); err != nil {
+		return err
+	}
+	if c.SonarRateLimit < 0 {
+		return errors.New("sonar rate limit must not be negative")
+	}
+	if c.SonarRateCoreShare <= 0 || c.SonarRateCoreShare >= 1 {
+		return errors.New("sonar rate core share must be between 0 and 1 exclusive")
+	}
+	if c.CycleDeadline < 0 {
+		return errors.New("cycle deadline must not be negative")
+	}
+	if _, err := c.QuietWindow(); err != nil {
+		return err
+	}
+	if c.SnapshotRetention < 0 {
+		return errors.New("snapshot retention must not be negative")
+	}
+	if _, err := c.SnapshotBucket(); err != nil {
+		return err
+	}
+	if _, err := c.ReportBucket(); err != nil {
+		return err
+	}
+	if c.MaxStaleness < 0 {
+		return errors.New("max staleness must not be negative")
+	}
+	if c.MetricTTL < 0 {
+		return errors.New("metric TTL must not be negative")
+	}
+	if c.TopRules < 0 {
+		return errors.New("number of top rules must not be negative")
+	}
+	if _, err := c.Metrics(); err != nil {
+		return err
+	}
+	if _, err := c.AgeBuckets(); err != nil {
+		return err
+	}
+	if _, err := c.LabelLimits(); err != nil {
+		return err
+	}
+	if c.HTTPReadTimeout < 0 || c.HTTPWriteTimeout < 0 || c.HTTPIdleTimeout < 0 {
+		return errors.New("HTTP timeouts must not be negative")
+	}
+	if c.HTTPMaxHeaderBytes <= 0 {
+		return errors.New("max HTTP header bytes must be positive")
+	}
+	if c.MaxInterval != 0 && c.MaxInterval < c.ScrapeTimeout {
+		return errors.New("max interval must not be less than scrape timeout")
+	}
+	if c.PressureErrorRatio < 0 || c.PressureErrorRatio > 1 {
+		return errors.New("pressure error ratio must be between 0 and 1")
+	}
+	if c.SonarHTTPVersion != "" && c.SonarHTTPVersion != "1.1" && c.SonarHTTPVersion != "2" {
+		return fmt.Errorf("unsupported Sonar HTTP version: %s", c.SonarHTTPVersion)
+	}
+	if c.SonarTimeout < 0 || c.SonarRetries < 0 {
+		return errors.New("timeout and retries of Sonar requests must not be negative")
+	}
+	if c.SonarTLSSessionCache < 0 || c.SonarReresolveInterval < 0 {
+		return errors.New("reresolve interval and TLS session cache of Sonar must not be negative")
+	}
+	if _, err := c.Proxy(); err != nil {
+		return err
+	}
+	if _, err := c.ExternalURL(); err != nil {
+		return err
+	}
+	if _, err := c.Hosts(); err != nil {
+		return err
+	}
+	if _, err := c.DNSServer(); err != nil {
+		return err
+	}
+	if _, err := c.SSH(); err != nil {
+		return err
+	}
+	if _, err := c.Headers(); err != nil {
+		return err
+	}
+	if _, err := c.OAuth2(); err != nil {
+		return err
+	}
+	if c.RequestBudget < 0 {
+		return errors.New("request budget must not be negative")
+	}
+	if c.MinSuccessRatio < 0 || c.MinSuccessRatio > 1 {
+		return errors.New("min success ratio must be between 0 and 1")
+	}
+	if c.CollectSlices < 1 {
+		return errors.New("number of collect slices must be positive")
+	}
+	if c.ShardCount < 1 || c.ShardIndex < 0 || c.ShardIndex >= c.ShardCount {
+		return fmt.Errorf("shard index must be between 0 and %d", c.ShardCount-1)
+	}
+	return nil
+}
+
+// AgeBuckets returns ascending ages in days open issues are counted by
+func (c *Config) AgeBuckets() ([]int, error) {
+	if c.IssueAgeBuckets == "" {
+		return nil, nil
+	}
+	var buckets []int
+	for _, s := range strings.Split(c.IssueAgeBuckets, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid issue age bucket %q, positive number of days expected", s)
+		}
+		if len(buckets) > 0 && days <= buckets[len(buckets)-1] {
+			return nil, errors.New("issue age buckets must be ascending")
+		}
+		buckets = append(buckets, days)
+	}
+	return buckets, nil
+}
+
+// QuietWindow returns quiet hours collection is paused during. Nil if not configured
+func (c *Config) QuietWindow() (*exporter.QuietHours, error) {
+	if c.QuietHours == "" {
+		return nil, nil
+	}
+	loc := time.Local
+	if c.QuietHoursTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(c.QuietHoursTimezone); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours time zone: %w", err)
+		}
+	}
+	return exporter.ParseQuietHours(c.QuietHours, loc)
+}
+
+// SnapshotBucket returns bucket snapshots of exported series are uploaded to. Nil if snapshots are disabled
+func (c *Config) SnapshotBucket() (*objstore.Bucket, error) {
+	if c.SnapshotURL == "" {
+		return nil, nil
+	}
+	bucket, err := objstore.NewBucket(c.SnapshotURL, c.SnapshotRegion, c.SnapshotAccessKey, c.SnapshotSecretKey,
+		c.SnapshotRetention, exporter.IsArchiveName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+// ReportBucket returns bucket CSV reports are uploaded to. Nil if the upload is disabled
+func (c *Config) ReportBucket() (*objstore.Bucket, error) {
+	if c.ReportURL == "" {
+		return nil, nil
+	}
+	// reports of the same day replace each other, so nothing is left to expire
+	bucket, err := objstore.NewBucket(c.ReportURL, c.SnapshotRegion, c.SnapshotAccessKey, c.SnapshotSecretKey, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+// LabelLimits returns max numbers of distinct values by label name
+func (c *Config) LabelLimits() (map[string]int, error) {
+	limits := map[string]int{}
+	if c.MaxLabelValues == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(c.MaxLabelValues, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid label limit %q, label=number expected", pair)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || max <= 0 {
+			return nil, fmt.Errorf("invalid label limit %q, positive number expected", pair)
+		}
+		label := strings.TrimSpace(parts[0])
+		if err := exporter.ValidateLabelName(label); err != nil {
+			return nil, fmt.Errorf("invalid label limit %q: %w", pair, err)
+		}
+		limits[label] = max
+	}
+	return limits, nil
+}
+
+// Metrics returns keys of collected metrics listed in configuration file or by preset.
+// Empty means all metrics are discovered
+func (c *Config) Metrics() ([]string, error) {
+	if c.MetricsPreset == "" {
+		return c.File.Metrics, nil
+	}
+	if len(c.File.Metrics) > 0 {
+		return nil, errors.New("metrics preset and metrics of config file are mutually exclusive")
+	}
+	return exporter.MetricPreset(c.MetricsPreset)
+}
+
+// ExternalURL returns URL exporter is reachable at externally. Nil if not configured
+func (c *Config) ExternalURL() (*url.URL, error) {
+	if c.WebExternalURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(c.WebExternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid web external URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid web external URL %q, absolute http or https URL expected", c.WebExternalURL)
+	}
+	return u, nil
+}
+
+// RoutePrefix returns path prefix of exporter's endpoints without trailing slash. Empty if served at root
+func (c *Config) RoutePrefix() string {
+	prefix := c.WebRoutePrefix
+	// external URL is validated already
+	if u, _ := c.ExternalURL(); prefix == "" && u != nil {
+		prefix = u.Path
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		return "/" + prefix
+	}
+	return ""
+}
+
+// Proxy returns URL of proxy Sonar is reached through. Nil if not configured
+func (c *Config) Proxy() (*url.URL, error) {
+	if c.SonarProxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(c.SonarProxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sonar proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unsupported Sonar proxy scheme %q, http, https or socks5 expected", u.Scheme)
+	}
+}
+
+// Headers returns static headers sent with every Sonar request
+func (c *Config) Headers() (http.Header, error) {
+	headers := http.Header{}
+	for _, header := range c.SonarHeaders {
+		parts := strings.SplitN(header, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid Sonar header %q, name=value expected", header)
+		}
+		headers.Add(name, strings.TrimSpace(parts[1]))
+	}
+	return headers, nil
+}
+
+// Hosts returns IP addresses by Sonar host names connections are made to instead of resolving the hosts
+func (c *Config) Hosts() (map[string]string, error) {
+	hosts := map[string]string{}
+	for _, mapping := range c.SonarHosts {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || net.ParseIP(strings.TrimSpace(parts[1])) == nil {
+			return nil, fmt.Errorf("invalid Sonar host mapping %q, host=ip expected", mapping)
+		}
+		hosts[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return hosts, nil
+}
+
+// DNSServer returns address of DNS server Sonar host is resolved with. Port defaults to 53. Empty if not configured
+func (c *Config) DNSServer() (string, error) {
+	if c.SonarDNSServer == "" {
+		return "", nil
+	}
+	if _, _, err := net.SplitHostPort(c.SonarDNSServer); err == nil {
+		return c.SonarDNSServer, nil
+	}
+	if net.ParseIP(strings.Trim(c.SonarDNSServer, "[]")) == nil {
+		return "", fmt.Errorf("invalid Sonar DNS server %q, ip or ip:port expected", c.SonarDNSServer)
+	}
+	return net.JoinHostPort(strings.Trim(c.SonarDNSServer, "[]"), "53"), nil
+}
+
+// SSH returns configuration of SSH jump host Sonar is reached through. Port defaults to 22. Nil if not configured
+func (c *Config) SSH() (*sonar.SSHConfig, error) {
+	if c.SonarSSHHost == "" {
+		return nil, nil
+	}
+	if c.SonarSSHUser == "" || c.SonarSSHKey == "" {
+		return nil, errors.New("user and key of SSH jump host are required")
+	}
+	addr := c.SonarSSHHost
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "22")
+	}
+	return &sonar.SSHConfig{
+		Addr:           addr,
+		User:           c.SonarSSHUser,
+		KeyFile:        c.SonarSSHKey,
+		KnownHostsFile: c.SonarSSHKnownHosts,
+	}, nil
+}
+
+// OAuth2 returns configuration of OAuth2 gateway Sonar is fronted with. Nil if not configured
+func (c *Config) OAuth2() (*sonar.OAuth2Config, error) {
+	if c.OAuth2TokenURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(c.OAuth2TokenURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OAuth2 token URL: %s", c.OAuth2TokenURL)
+	}
+	if c.OAuth2ClientID == "" || c.OAuth2ClientSecret == "" {
+		return nil, errors.New("OAuth2 client ID and secret are required")
+	}
+	if c.OAuth2Header == "" {
+		return nil, errors.New("OAuth2 header must not be empty")
+	}
+	var scopes []string
+	for _, scope := range strings.Split(c.OAuth2Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return &sonar.OAuth2Config{
+		TokenURL:     c.OAuth2TokenURL,
+		ClientID:     c.OAuth2ClientID,
+		ClientSecret: c.OAuth2ClientSecret,
+		Scopes:       scopes,
+		Header:       c.OAuth2Header,
+	}, nil
+}
+
+// stringList is a flag value collecting every occurrence of repeated flag
+type stringList []string
+
+// String implements flag.Value
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value
+func (l *stringList) Set(val string) error {
+	*l = append(*l, val)
+	return nil
+}
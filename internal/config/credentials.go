@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// LoadCredentials reads Sonar user and password from files if configured,
+// e.g. from a mounted Kubernetes secret. Values provided with flags are used otherwise
+func (c *Config) LoadCredentials() (user, password string, err error) {
+	user, password = c.SonarUser, c.SonarPassword
+	if c.SonarUserFile != "" {
+		if user, err = readSecret(c.SonarUserFile); err != nil {
+			return "", "", err
+		}
+	}
+	if c.SonarPasswordFile != "" {
+		if password, err = readSecret(c.SonarPasswordFile); err != nil {
+			return "", "", err
+		}
+	}
+	return user, password, nil
+}
+
+// HasCredentialFiles reports whether credentials are read from files and may be reloaded
+func (c *Config) HasCredentialFiles() bool {
+	return c.SonarUserFile != "" || c.SonarPasswordFile != ""
+}
+
+func readSecret(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read credentials: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+)
+
+const masked = "******"
+
+// sensitiveFlags are masked in effective configuration. User info of URLs in other flags is masked as well.
+// User is masked since Sonar tokens are usually passed as users with empty passwords
+var sensitiveFlags = map[string]struct{}{
+	"user": {}, "password": {}, "notify-webhook": {}, "sonar-proxy": {}, "oauth2-client-secret": {}, "sonar-header": {},
+	"snapshot-access-key": {}, "snapshot-secret-key": {}, "refresh-token": {},
+}
+
+// Effective is an effective configuration with secrets masked
+type Effective struct {
+	Flags       map[string]FlagValue `json:"flags"`
+	File        *File                `json:"file,omitempty"`
+	Conversions exporter.Conversions `json:"conversions"`
+}
+
+// FlagValue is a value of a flag and its origin: default, env or flag
+type FlagValue struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// NewEffective builds effective configuration from parsed flags and config file
+func NewEffective(fs *flag.FlagSet, cfg *Config) *Effective {
+	set := map[string]struct{}{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = struct{}{}
+	})
+
+	e := &Effective{Flags: map[string]FlagValue{}, File: cfg.File, Conversions: cfg.Conversions()}
+	fs.VisitAll(func(f *flag.Flag) {
+		v := FlagValue{Value: f.Value.String(), Source: "default"}
+		if _, found := cfg.envFlags[f.Name]; found {
+			v.Source = "env"
+		}
+		if _, found := set[f.Name]; found {
+			v.Source = "flag"
+		}
+		if _, sensitive := sensitiveFlags[f.Name]; sensitive && v.Value != "" {
+			v.Value = masked
+		}
+		v.Value = redactURLs(v.Value)
+		e.Flags[f.Name] = v
+	})
+	return e
+}
+
+// redactURLs masks user info of URLs in comma separated value, e.g. credentials of Sonar or bucket URL
+func redactURLs(value string) string {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		if u, err := url.Parse(part); err == nil && u.User != nil {
+			u.User = nil
+			parts[i] = strings.Replace(u.String(), "//", "//"+masked+"@", 1)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (e *Effective) String() string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// ServeHTTP serves effective configuration as JSON
+func (e *Effective) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(e); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix prefixes environment variables flags may be provided with
+const EnvPrefix = "SONAR_EXPORTER_"
+
+// EnvName returns environment variable of the flag, e.g. SONAR_EXPORTER_SCRAPE_TIMEOUT for -scrape-timeout
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// bindEnv sets flags not provided on command line from environment variables. Must be called after parsing
+// arguments, so flags provided on command line take precedence and values of repeatable flags, e.g. -sonar-header,
+// replace ones of environment instead of being appended to them. Values of repeatable flags are separated
+// with commas in environment. Returns names of flags set from environment
+func bindEnv(fs *flag.FlagSet) (map[string]struct{}, error) {
+	provided := map[string]struct{}{}
+	fs.Visit(func(f *flag.Flag) {
+		provided[f.Name] = struct{}{}
+	})
+
+	set := map[string]struct{}{}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, found := provided[f.Name]; found || err != nil {
+			return
+		}
+		val, found := os.LookupEnv(EnvName(f.Name))
+		if !found {
+			return
+		}
+		values := []string{val}
+		// a variable can not be repeated, so values of repeatable flags are separated with commas
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = splitList(val)
+		}
+		// value is set directly, so flag is not considered as provided on command line
+		for _, v := range values {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("invalid value %q of %s: %w", val, EnvName(f.Name), setErr)
+				return
+			}
+		}
+		set[f.Name] = struct{}{}
+	})
+	return set, err
+}
+
+// splitList splits comma separated values skipping blank ones
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
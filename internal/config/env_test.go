@@ -0,0 +1,107 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setEnv sets environment variables for the duration of the test
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for name, val := range env {
+		prev, found := os.LookupEnv(name)
+		if err := os.Setenv(name, val); err != nil {
+			t.Fatal(err)
+		}
+		name := name
+		t.Cleanup(func() {
+			if found {
+				_ = os.Setenv(name, prev)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+// parse parses arguments with a flag set of its own
+func parse(t *testing.T, args ...string) (*Config, *flag.FlagSet) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cfg, err := Parse(fs, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, fs
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("sonar-ssh-known-hosts"); got != "SONAR_EXPORTER_SONAR_SSH_KNOWN_HOSTS" {
+		t.Errorf("environment variable %s, want SONAR_EXPORTER_SONAR_SSH_KNOWN_HOSTS", got)
+	}
+}
+
+func TestBindEnv(t *testing.T) {
+	setEnv(t, map[string]string{
+		"SONAR_EXPORTER_URL":            "https://sonar.example.com",
+		"SONAR_EXPORTER_SCRAPE_TIMEOUT": "30s",
+		"SONAR_EXPORTER_SONAR_HEADER":   "X-Api-Key=secret, X-Tenant=core,",
+		"SONAR_EXPORTER_SONAR_HOST":     "sonar.example.com=10.0.0.5",
+	})
+	cfg, fs := parse(t)
+
+	if cfg.SonarURL != "https://sonar.example.com" || cfg.ScrapeTimeout != 30*time.Second {
+		t.Errorf("url %q and scrape timeout %s, want ones of environment", cfg.SonarURL, cfg.ScrapeTimeout)
+	}
+	if want := (stringList{"X-Api-Key=secret", "X-Tenant=core"}); !reflect.DeepEqual(cfg.SonarHeaders, want) {
+		t.Errorf("headers %q, want %q", cfg.SonarHeaders, want)
+	}
+	if want := (stringList{"sonar.example.com=10.0.0.5"}); !reflect.DeepEqual(cfg.SonarHosts, want) {
+		t.Errorf("hosts %q, want %q", cfg.SonarHosts, want)
+	}
+	for _, name := range []string{"url", "scrape-timeout", "sonar-header", "sonar-host"} {
+		if v := NewEffective(fs, cfg).Flags[name]; v.Source != "env" {
+			t.Errorf("%s is from %s, want env", name, v.Source)
+		}
+	}
+}
+
+func TestCommandLineWinsOverEnv(t *testing.T) {
+	setEnv(t, map[string]string{
+		"SONAR_EXPORTER_URL":          "https://sonar.example.com",
+		"SONAR_EXPORTER_CONCURRENCY":  "8",
+		"SONAR_EXPORTER_SONAR_HEADER": "X-Api-Key=env,X-Tenant=env",
+	})
+	cfg, fs := parse(t, "-url", "https://sonar-dr.example.com", "-sonar-header", "X-Api-Key=flag")
+
+	if cfg.SonarURL != "https://sonar-dr.example.com" {
+		t.Errorf("url %q, want one of command line", cfg.SonarURL)
+	}
+	// repeatable flags of command line replace values of environment rather than extend them
+	if want := (stringList{"X-Api-Key=flag"}); !reflect.DeepEqual(cfg.SonarHeaders, want) {
+		t.Errorf("headers %q, want %q", cfg.SonarHeaders, want)
+	}
+	if cfg.Concurrency != 8 {
+		t.Errorf("concurrency %d, want 8 of environment", cfg.Concurrency)
+	}
+	effective := NewEffective(fs, cfg)
+	for name, want := range map[string]string{"url": "flag", "sonar-header": "flag", "concurrency": "env"} {
+		if v := effective.Flags[name]; v.Source != want {
+			t.Errorf("%s is from %s, want %s", name, v.Source, want)
+		}
+	}
+}
+
+func TestInvalidEnv(t *testing.T) {
+	setEnv(t, map[string]string{"SONAR_EXPORTER_CONCURRENCY": "many"})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if _, err := Parse(fs, nil); err == nil {
+		t.Error("invalid environment variable accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+)
+
+// File is an optional YAML configuration file complementing command line flags
+type File struct {
+	// Conversions override default conversions of measure values by metric type
+	Conversions exporter.Conversions `yaml:"conversions" json:"conversions,omitempty"`
+	// Labels define handling of projects lacking labels converted from tags by label name
+	Labels exporter.LabelPolicies `yaml:"labels" json:"labels,omitempty"`
+	// Metrics are keys of collected metrics. Discovery of metrics is skipped if provided
+	Metrics []string `yaml:"metrics" json:"metrics,omitempty"`
+	// Aliases are historical keys of projects renamed in Sonar by their current keys
+	Aliases exporter.Aliases `yaml:"aliases" json:"aliases,omitempty"`
+	// Merge are keys of projects merged into logical components by their keys, e.g. monorepos split into projects
+	Merge exporter.Merges `yaml:"merge" json:"merge,omitempty"`
+}
+
+// LoadFile reads configuration file
+func LoadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	f := &File{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("unable to parse config file: %w", err)
+	}
+	if err := f.Labels.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := f.Aliases.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := f.Merge.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	return f, nil
+}
+
+// Conversions returns default conversion table merged with one from config file
+func (c *Config) Conversions() exporter.Conversions {
+	return exporter.DefaultConversions().Merge(c.File.Conversions)
+}
@@ -0,0 +1,211 @@
+// Package diff compares measures of projects between two snapshots of exported series, e.g. for quarterly
+// quality reviews without Prometheus range queries
+package diff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	fetchTimeout   = 30 * time.Second
+	componentLabel = "component"
+)
+
+// volatileMetrics change every cycle regardless of projects' quality, so they are not compared
+var volatileMetrics = map[string]struct{}{
+	"sonar_component_data_age_seconds":     {},
+	"sonar_exporter_ingestion_lag_seconds": {},
+	"sonar_component_up":                   {},
+}
+
+// Values are values of series by project key and series name with labels except the component one,
+// e.g. sonar_alert_status_level{level="OK"}
+type Values map[string]map[string]float64
+
+// Load loads values of a snapshot. Source is either a file or an URL of snapshot archived by the exporter
+// or an URL of metrics endpoint of a running exporter, so a snapshot may be compared with live values
+func Load(source string) (Values, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		content, err = fetch(source)
+	} else {
+		content, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", source, err)
+	}
+
+	// archived snapshots are gzipped, metrics endpoint serves text format
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		snapshot, err := exporter.ReadArchive(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", source, err)
+		}
+		values := Values{}
+		for _, s := range snapshot.Series {
+			values.add(s.Metric, s.Labels, s.Value)
+		}
+		return values, nil
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", source, err)
+	}
+	values := Values{}
+	for name, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			switch {
+			case m.Gauge != nil:
+				values.add(name, labels, m.GetGauge().GetValue())
+			case m.Counter != nil:
+				values.add(name, labels, m.GetCounter().GetValue())
+			case m.Untyped != nil:
+				values.add(name, labels, m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return values, nil
+}
+
+// fetch downloads content of the URL
+func fetch(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build request: %w", err)
+	}
+	// text format is requested explicitly since protobuf one may be negotiated otherwise
+	rq.Header.Set("Accept", string(expfmt.FmtText))
+	rs, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response: %w", err)
+	}
+	if rs.StatusCode >= 400 {
+		return nil, fmt.Errorf("request failed. status code %d", rs.StatusCode)
+	}
+	return body, nil
+}
+
+// add adds value of series. Series of no project and volatile ones are ignored
+func (v Values) add(metric string, labels map[string]string, value float64) {
+	project, found := labels[componentLabel]
+	if !found {
+		return
+	}
+	if _, volatile := volatileMetrics[metric]; volatile {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != componentLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	if len(pairs) > 0 {
+		metric += "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	if v[project] == nil {
+		v[project] = map[string]float64{}
+	}
+	v[project][metric] = value
+}
+
+// Write prints changes of values between old and current snapshots by project. Unchanged projects are omitted
+func Write(w io.Writer, old, current Values) error {
+	projects := make([]string, 0, len(current))
+	for project := range old {
+		projects = append(projects, project)
+	}
+	for project := range current {
+		if _, found := old[project]; !found {
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+
+	var b strings.Builder
+	for _, project := range projects {
+		before, existed := old[project]
+		after, exists := current[project]
+		switch {
+		case !existed:
+			fmt.Fprintf(&b, "%s: added\n", project)
+			continue
+		case !exists:
+			fmt.Fprintf(&b, "%s: removed\n", project)
+			continue
+		}
+
+		var lines []string
+		for name, was := range before {
+			is, found := after[name]
+			switch {
+			case !found:
+				lines = append(lines, fmt.Sprintf("  %s: %s -> n/a", name, formatValue(was)))
+			case is != was && !(math.IsNaN(is) && math.IsNaN(was)):
+				lines = append(lines, fmt.Sprintf("  %s: %s -> %s (%s)", name, formatValue(was), formatValue(is), formatDelta(is-was)))
+			}
+		}
+		for name, is := range after {
+			if _, found := before[name]; !found {
+				lines = append(lines, fmt.Sprintf("  %s: n/a -> %s", name, formatValue(is)))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		sort.Strings(lines)
+		fmt.Fprintf(&b, "%s:\n%s\n", project, strings.Join(lines, "\n"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatDelta formats signed difference rounded to get rid of floating point noise, e.g. 0.30000000000000004
+func formatDelta(d float64) string {
+	d = math.Round(d*1e6) / 1e6
+	if d > 0 {
+		return "+" + formatValue(d)
+	}
+	return formatValue(d)
+}
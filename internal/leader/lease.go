@@ -0,0 +1,306 @@
+// Package leader implements leader election based on Kubernetes Lease objects
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	microTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var errNotFound = errors.New("lease not found")
+
+// Elector acquires and renews a Lease. Only one elector holding the lease is a leader
+type Elector struct {
+	c    *http.Client
+	host string
+	// tokenFile is re-read on every request since projected service account tokens rotate
+	tokenFile string
+	tokenMut  sync.Mutex
+	token     string
+	namespace string
+	name      string
+	identity  string
+
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+
+	// leading is 1 if elector holds the lease. Accessed atomically
+	leading int32
+
+	// observed is a resource version of the lease seen last time and observedAt is a local time it was seen at.
+	// Expiry of leases held by others is judged by the local clock only, so clock skew between pods does not matter
+	observed   string
+	observedAt time.Time
+}
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// NewInCluster creates elector which uses pod's service account to access Kubernetes API.
+// Empty namespace means namespace of the pod
+func NewInCluster(namespace, name, identity string, leaseDuration time.Duration) (*Elector, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account token: %w", err)
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("unable to parse service account CA")
+	}
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	return &Elector{
+		c: &http.Client{
+			Timeout:   leaseDuration / 2,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+		host:          "https://" + net.JoinHostPort(host, port),
+		tokenFile:     serviceAccountDir + "/token",
+		token:         strings.TrimSpace(string(token)),
+		namespace:     namespace,
+		name:          name,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		retryPeriod:   leaseDuration / 3,
+	}, nil
+}
+
+// IsLeader reports whether elector currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leading) == 1
+}
+
+// Run tries to acquire and renew the lease until done is closed. Releases the lease on exit
+func (e *Elector) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+
+	for {
+		e.setLeading(e.tryAcquireOrRenew())
+
+		select {
+		case <-done:
+			if e.IsLeader() {
+				if err := e.release(); err != nil {
+					log.Printf("Unable to release lease: %v", err)
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) setLeading(leading bool) {
+	var val int32
+	if leading {
+		val = 1
+	}
+	if atomic.SwapInt32(&e.leading, val) != val {
+		if leading {
+			log.Printf("Acquired lease %s/%s as %s", e.namespace, e.name, e.identity)
+		} else {
+			log.Printf("Lost lease %s/%s", e.namespace, e.name)
+		}
+	}
+}
+
+func (e *Elector) tryAcquireOrRenew() bool {
+	now := time.Now()
+	l, err := e.get()
+	if errors.Is(err, errNotFound) {
+		l = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: e.name, Namespace: e.namespace},
+			Spec:       e.spec(now, now.Format(microTimeFormat), 0),
+		}
+		if err := e.execute(http.MethodPost, e.leasesURL(), l, nil); err != nil {
+			log.Printf("Unable to create lease: %v", err)
+			return false
+		}
+		return true
+	}
+	if err != nil {
+		log.Printf("Unable to get lease: %v", err)
+		return false
+	}
+
+	e.observe(l, now)
+	if l.Spec.HolderIdentity != e.identity && !e.expired(l, now) {
+		return false
+	}
+
+	if l.Spec.HolderIdentity == e.identity {
+		l.Spec = e.spec(now, l.Spec.AcquireTime, l.Spec.LeaseTransitions)
+	} else {
+		l.Spec = e.spec(now, now.Format(microTimeFormat), l.Spec.LeaseTransitions+1)
+	}
+	// resource version guarantees update fails if somebody else updated the lease in between
+	if err := e.execute(http.MethodPut, e.leaseURL(), l, nil); err != nil {
+		log.Printf("Unable to update lease: %v", err)
+		return false
+	}
+	return true
+}
+
+func (e *Elector) release() error {
+	l, err := e.get()
+	if err != nil {
+		return err
+	}
+	if l.Spec.HolderIdentity != e.identity {
+		return nil
+	}
+	l.Spec.HolderIdentity = ""
+	return e.execute(http.MethodPut, e.leaseURL(), l, nil)
+}
+
+func (e *Elector) spec(now time.Time, acquireTime string, transitions int) leaseSpec {
+	return leaseSpec{
+		HolderIdentity:       e.identity,
+		LeaseDurationSeconds: int(e.leaseDuration.Seconds()),
+		AcquireTime:          acquireTime,
+		RenewTime:            now.Format(microTimeFormat),
+		LeaseTransitions:     transitions,
+	}
+}
+
+// observe remembers local time the lease was seen changed at, e.g. renewed by its holder
+func (e *Elector) observe(l *lease, now time.Time) {
+	version := l.Metadata.ResourceVersion + "/" + l.Spec.HolderIdentity + "/" + l.Spec.RenewTime
+	if version != e.observed {
+		e.observed, e.observedAt = version, now
+	}
+}
+
+// expired reports whether holder of the lease has not renewed it for its duration. Renew time recorded
+// by the holder is not compared with the local clock, only the time the lease was seen changed at
+func (e *Elector) expired(l *lease, now time.Time) bool {
+	if l.Spec.HolderIdentity == "" {
+		return true
+	}
+	return e.observedAt.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// bearer returns service account token. Token file is re-read every time, since projected tokens rotate
+// and expire. The last token read is used if the file is unavailable
+func (e *Elector) bearer() string {
+	e.tokenMut.Lock()
+	defer e.tokenMut.Unlock()
+	if e.tokenFile == "" {
+		return e.token
+	}
+	token, err := ioutil.ReadFile(e.tokenFile)
+	if err != nil {
+		log.Printf("Unable to read service account token, using the last one: %v", err)
+		return e.token
+	}
+	if t := strings.TrimSpace(string(token)); t != "" {
+		e.token = t
+	}
+	return e.token
+}
+
+func (e *Elector) get() (*lease, error) {
+	var l lease
+	if err := e.execute(http.MethodGet, e.leaseURL(), nil, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (e *Elector) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", e.host, e.namespace)
+}
+
+func (e *Elector) leaseURL() string {
+	return e.leasesURL() + "/" + e.name
+}
+
+func (e *Elector) execute(method, u string, body, res interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("unable to marshal lease: %w", err)
+		}
+	}
+	rq, err := http.NewRequestWithContext(context.Background(), method, u, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+	rq.Header.Set("Authorization", "Bearer "+e.bearer())
+	rq.Header.Set("Content-Type", "application/json")
+
+	rs, err := e.c.Do(rq)
+	if err != nil {
+		return fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	if rs.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if rs.StatusCode >= 400 {
+		rsBody, _ := ioutil.ReadAll(rs.Body)
+		return fmt.Errorf("request failed. status code %d. Error: %s", rs.StatusCode, string(rsBody))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(rs.Body).Decode(res)
+}
@@ -0,0 +1,123 @@
+package leader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLeases is a fake Kubernetes API serving a single Lease
+type fakeLeases struct {
+	mut     sync.Mutex
+	lease   *lease
+	version int
+	tokens  []string
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.tokens = append(f.tokens, rq.Header.Get("Authorization"))
+	switch rq.Method {
+	case http.MethodGet:
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.lease)
+	case http.MethodPost, http.MethodPut:
+		var l lease
+		if err := json.NewDecoder(rq.Body).Decode(&l); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.set(&l)
+	}
+}
+
+// set stores the lease bumping its resource version
+func (f *fakeLeases) set(l *lease) {
+	f.version++
+	l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.lease = l
+}
+
+// renew renews the lease on behalf of another holder whose clock is skewed by an hour
+func (f *fakeLeases) renew(holder string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.set(&lease{Spec: leaseSpec{
+		HolderIdentity:       holder,
+		LeaseDurationSeconds: 1,
+		RenewTime:            time.Now().Add(-time.Hour).Format(microTimeFormat),
+	}})
+}
+
+func newTestElector(t *testing.T, f *fakeLeases) (*Elector, string) {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &Elector{
+		c:             srv.Client(),
+		host:          srv.URL,
+		tokenFile:     tokenFile,
+		namespace:     "default",
+		name:          "exporter",
+		identity:      "me",
+		leaseDuration: time.Second,
+		retryPeriod:   time.Second / 3,
+	}, tokenFile
+}
+
+func TestSkewedHolderKeepsLease(t *testing.T) {
+	f := &fakeLeases{}
+	e, _ := newTestElector(t, f)
+
+	// renew time of the holder is an hour behind, yet the lease is renewed in time
+	for i := 0; i < 4; i++ {
+		f.renew("other")
+		if e.tryAcquireOrRenew() {
+			t.Fatal("lease renewed by its holder is taken over")
+		}
+		time.Sleep(400 * time.Millisecond)
+	}
+
+	// holder stops renewing, the lease is taken over once not seen renewed for its duration
+	if e.tryAcquireOrRenew() {
+		t.Fatal("lease is taken over before its duration passed")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if !e.tryAcquireOrRenew() {
+		t.Fatal("expired lease is not taken over")
+	}
+	if f.lease.Spec.HolderIdentity != "me" {
+		t.Errorf("lease is held by %q", f.lease.Spec.HolderIdentity)
+	}
+}
+
+func TestRotatedTokenIsUsed(t *testing.T) {
+	f := &fakeLeases{}
+	e, tokenFile := newTestElector(t, f)
+
+	e.tryAcquireOrRenew()
+	if err := ioutil.WriteFile(tokenFile, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e.tryAcquireOrRenew()
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if first, last := f.tokens[0], f.tokens[len(f.tokens)-1]; first != "Bearer first" || last != "Bearer second" {
+		t.Errorf("tokens sent: %q, want rotated token to be used", f.tokens)
+	}
+}
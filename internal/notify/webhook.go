@@ -0,0 +1,64 @@
+// Package notify posts exporter's notifications to chat webhooks
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const sendTimeout = 10 * time.Second
+
+// Webhook posts messages to Slack or Microsoft Teams incoming webhook.
+// Both accept JSON payload with 'text' field
+type Webhook struct {
+	c   *http.Client
+	url string
+	// prefix is prepended to every message to tell exporter instances apart
+	prefix string
+}
+
+// NewWebhook creates notifier posting to webhook URL. Prefix is prepended to every message
+func NewWebhook(url, prefix string) *Webhook {
+	return &Webhook{c: &http.Client{Timeout: sendTimeout}, url: url, prefix: prefix}
+}
+
+// Notify sends message and logs failures
+func (w *Webhook) Notify(msg string) {
+	if err := w.Send(msg); err != nil {
+		log.Printf("Unable to send notification: %v", err)
+	}
+}
+
+// Send posts message to the webhook
+func (w *Webhook) Send(msg string) error {
+	body, err := json.Marshal(map[string]string{"text": w.prefix + msg})
+	if err != nil {
+		return fmt.Errorf("unable to marshal notification: %w", err)
+	}
+	rq, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+	rq.Header.Set("Content-Type", "application/json")
+
+	rs, err := w.c.Do(rq)
+	if err != nil {
+		return fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	if rs.StatusCode >= 400 {
+		rsBody, _ := ioutil.ReadAll(rs.Body)
+		return fmt.Errorf("request failed. status code %d. Error: %s", rs.StatusCode, string(rsBody))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package objstore uploads files to S3 compatible object storage, e.g. AWS S3, MinIO or Google Cloud Storage
+// in interoperability mode with HMAC keys
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Bucket is a bucket of S3 compatible storage addressed in path style, so it works with any endpoint
+type Bucket struct {
+	c        *http.Client
+	endpoint *url.URL
+	name     string
+	// prefix is prepended to keys of all objects
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	// retention is an age objects under the prefix are deleted after. Zero keeps objects forever
+	retention time.Duration
+	// expirable reports whether object of the name, relative to the prefix, is subject to retention
+	expirable func(name string) bool
+}
+
+// Object is an object of the bucket
+type Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listResult struct {
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// NewBucket creates bucket of URL in form of http(s)://endpoint/bucket/prefix,
+// e.g. https://s3.eu-west-1.amazonaws.com/sonar-archive/exporter/. Prefix is a directory, so a trailing slash
+// is added if missing. Retention requires a prefix and applies to objects right under it names of which
+// are expirable only, so nothing else stored in the bucket is ever deleted
+func NewBucket(rawURL, region, accessKey, secretKey string, retention time.Duration, expirable func(name string) bool) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse bucket URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("bucket URL must be http or https")
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	name, prefix := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		name, prefix = path[:i], path[i+1:]
+	}
+	if name == "" {
+		return nil, errors.New("bucket URL lacks bucket name")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("access key and secret key are required")
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if retention > 0 && (prefix == "" || expirable == nil) {
+		return nil, errors.New("bucket URL must have a prefix objects are deleted under once retention is set")
+	}
+	return &Bucket{
+		c:         &http.Client{Timeout: requestTimeout},
+		endpoint:  &url.URL{Scheme: u.Scheme, Host: u.Host},
+		name:      name,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		retention: retention,
+		expirable: expirable,
+	}, nil
+}
+
+// Archive uploads content under the prefix and deletes objects older than retention
+func (b *Bucket) Archive(name string, content []byte) error {
+	ctx := context.Background()
+	if err := b.Put(ctx, b.prefix+name, content); err != nil {
+		return err
+	}
+	if b.retention == 0 {
+		return nil
+	}
+	objects, err := b.List(ctx, b.prefix)
+	if err != nil {
+		return err
+	}
+	expired := time.Now().Add(-b.retention)
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, b.prefix)
+		if strings.Contains(name, "/") || !b.expirable(name) {
+			continue
+		}
+		if o.LastModified.Before(expired) {
+			if err := b.Delete(ctx, o.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Put uploads object of the key
+func (b *Bucket) Put(ctx context.Context, key string, content []byte) error {
+	_, err := b.execute(ctx, http.MethodPut, key, nil, content)
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete deletes object of the key
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	_, err := b.execute(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("unable to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List lists all objects keys of which start with the prefix
+func (b *Bucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := b.execute(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list objects: %w", err)
+		}
+		var page listResult
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal objects: %w", err)
+		}
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// execute executes signed request to object of the key or to the bucket itself if key is empty
+func (b *Bucket) execute(ctx context.Context, method, key string, query url.Values, content []byte) ([]byte, error) {
+	path := "/" + b.name + "/" + key
+	u := *b.endpoint
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	rq, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to build request: %w", err)
+	}
+	b.sign(rq, u.RawPath, u.RawQuery, content, time.Now().UTC())
+
+	rs, err := b.c.Do(rq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	body, err := ioutil.ReadAll(io.LimitReader(rs.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response: %w", err)
+	}
+	if rs.StatusCode >= 300 {
+		return nil, fmt.Errorf("request failed. status code %d. Error: %s", rs.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// sign signs request with AWS Signature Version 4
+func (b *Bucket) sign(rq *http.Request, path, query string, content []byte, now time.Time) {
+	date, timestamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	payloadHash := hexSHA256(content)
+	rq.Header.Set("X-Amz-Date", timestamp)
+	rq.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		rq.Method,
+		path,
+		query,
+		"host:" + rq.URL.Host + "\n" + "x-amz-content-sha256:" + payloadHash + "\n" + "x-amz-date:" + timestamp + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+b.secretKey), date)
+	for _, part := range []string{b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	rq.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		b.accessKey, scope, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+// canonicalQuery encodes query sorted by name as required by signature
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, val := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(val, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes all characters except unreserved ones. Slashes are kept unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
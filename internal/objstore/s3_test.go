@@ -0,0 +1,92 @@
+package objstore
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBucket serves listing, uploads and deletions of objects of a single bucket
+type fakeBucket struct {
+	mut     sync.Mutex
+	objects map[string]time.Time
+	deleted []string
+}
+
+func (f *fakeBucket) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	key := strings.TrimPrefix(rq.URL.Path, "/bucket/")
+	switch rq.Method {
+	case http.MethodPut:
+		f.objects[key] = time.Now()
+	case http.MethodDelete:
+		delete(f.objects, key)
+		f.deleted = append(f.deleted, key)
+	case http.MethodGet:
+		var res listResult
+		for k, modified := range f.objects {
+			if strings.HasPrefix(k, rq.URL.Query().Get("prefix")) {
+				res.Contents = append(res.Contents, Object{Key: k, LastModified: modified})
+			}
+		}
+		body, _ := xml.Marshal(res)
+		_, _ = w.Write(body)
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	fake := &fakeBucket{objects: map[string]time.Time{
+		"archive/2020-01-01T00-00-00Z.json.gz":        old,
+		"archive/notes.txt":                           old,
+		"archive/nested/2020-01-01T00-00-00Z.json.gz": old,
+		"archive-old/2020-01-01T00-00-00Z.json.gz":    old,
+		"backup.tar": old,
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	expirable := func(name string) bool { return strings.HasSuffix(name, ".json.gz") }
+	// prefix lacks trailing slash, so it must not match archive-old
+	b, err := NewBucket(srv.URL+"/bucket/archive", "us-east-1", "ak", "sk", 24*time.Hour, expirable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Archive("2026-10-15T05-30-00Z.json.gz", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.mut.Lock()
+	defer fake.mut.Unlock()
+	if len(fake.deleted) != 1 || fake.deleted[0] != "archive/2020-01-01T00-00-00Z.json.gz" {
+		t.Errorf("deleted %v, want archive/2020-01-01T00-00-00Z.json.gz only", fake.deleted)
+	}
+	var kept []string
+	for key := range fake.objects {
+		kept = append(kept, key)
+	}
+	sort.Strings(kept)
+	want := "archive-old/2020-01-01T00-00-00Z.json.gz,archive/2026-10-15T05-30-00Z.json.gz," +
+		"archive/nested/2020-01-01T00-00-00Z.json.gz,archive/notes.txt,backup.tar"
+	if got := strings.Join(kept, ","); got != want {
+		t.Errorf("kept %s, want %s", got, want)
+	}
+}
+
+func TestRetentionRequiresPrefix(t *testing.T) {
+	expirable := func(string) bool { return true }
+	for _, u := range []string{"https://s3.example.com/bucket", "https://s3.example.com/bucket/"} {
+		if _, err := NewBucket(u, "us-east-1", "ak", "sk", time.Hour, expirable); err == nil {
+			t.Errorf("bucket %s without prefix accepted with retention", u)
+		}
+		if _, err := NewBucket(u, "us-east-1", "ak", "sk", 0, nil); err != nil {
+			t.Errorf("bucket %s without retention rejected: %v", u, err)
+		}
+	}
+}
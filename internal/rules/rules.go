@@ -0,0 +1,272 @@
+// Package rules generates Prometheus alerting and recording rules for metrics exposed by the exporter
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// Options are parameters of generated rules
+type Options struct {
+	// Namespace is a prefix of metric names
+	Namespace string
+	// Group is a name of rule group
+	Group string
+	// Selector is a comma separated list of label matchers added to every expression, e.g. team="core"
+	Selector string
+	// Labels are attached to every alert
+	Labels map[string]string
+	// CoverageDrop is a drop of coverage in percentage points within CoverageWindow which fires an alert
+	CoverageDrop float64
+	// CoverageWindow is a period coverage drop is calculated for
+	CoverageWindow time.Duration
+	// NoAnalysis is a period without analysis after which project is considered abandoned
+	NoAnalysis time.Duration
+	// TokenExpiry is a time before Sonar token expiration starting from which an alert fires
+	TokenExpiry time.Duration
+	// For is a duration condition must hold before alerts fire
+	For time.Duration
+
+	// Families are metric families exposed by running exporter. Recording rules are generated only if provided
+	Families map[string]*dto.MetricFamily
+	// MinSeries is a number of series starting from which metric is pre-aggregated with recording rule
+	MinSeries int
+	// RollupLabels are labels metrics are aggregated by. Empty means all labels except component ones
+	RollupLabels []string
+}
+
+// DefaultOptions returns options generating rules for default exporter setup
+func DefaultOptions() Options {
+	return Options{
+		Namespace:      "sonar",
+		Group:          "sonarqube",
+		Labels:         map[string]string{"severity": "warning"},
+		CoverageDrop:   5,
+		CoverageWindow: 24 * time.Hour,
+		NoAnalysis:     30 * 24 * time.Hour,
+		TokenExpiry:    7 * 24 * time.Hour,
+		For:            15 * time.Minute,
+		MinSeries:      100,
+	}
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Generate produces Prometheus rules file
+func Generate(opts Options) ([]byte, error) {
+	if opts.Namespace == "" || opts.Group == "" {
+		return nil, errors.New("namespace and group are required")
+	}
+	file := ruleFile{Groups: []ruleGroup{{Name: opts.Group, Rules: alerts(opts)}}}
+	if recording := recordings(opts); len(recording) > 0 {
+		file.Groups = append(file.Groups, ruleGroup{Name: opts.Group + "-recording", Rules: recording})
+	}
+	content, err := yaml.Marshal(&file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal rules: %w", err)
+	}
+	return content, nil
+}
+
+// alerts returns alerting rules
+func alerts(opts Options) []rule {
+	forDuration := ""
+	if opts.For > 0 {
+		forDuration = model.Duration(opts.For).String()
+	}
+	alert := func(name, expr, summary string) rule {
+		return rule{
+			Alert:       name,
+			Expr:        expr,
+			For:         forDuration,
+			Labels:      opts.Labels,
+			Annotations: map[string]string{"summary": summary},
+		}
+	}
+
+	return []rule{
+		alert("SonarQualityGateFailing",
+			fmt.Sprintf("%s == 1", opts.series("alert_status_level", `level="ERROR"`)),
+			"Quality gate of {{ $labels.component }} is failing"),
+		alert("SonarCoverageDropped",
+			fmt.Sprintf("(%[1]s offset %[2]s) - %[1]s > %[3]g",
+				opts.series("coverage"), model.Duration(opts.CoverageWindow), opts.CoverageDrop),
+			fmt.Sprintf("Coverage of {{ $labels.component }} dropped by {{ $value | humanize }}%% within %s",
+				model.Duration(opts.CoverageWindow))),
+		alert("SonarNoRecentAnalysis",
+			fmt.Sprintf("time() - %s > %.0f", opts.series("component_analysis_timestamp_seconds"), opts.NoAnalysis.Seconds()),
+			fmt.Sprintf("{{ $labels.component }} has not been analyzed for more than %s", model.Duration(opts.NoAnalysis))),
+		alert("SonarExporterCollectionFailing",
+			// exporter's own metrics are not labeled with project labels, so selector is not applied
+			fmt.Sprintf("%s_exporter_consecutive_failures > 0", opts.Namespace),
+			"SonarQube exporter fails to collect measures"),
+		alert("SonarTokenExpiresSoon",
+			fmt.Sprintf("%s_exporter_token_expires_in_seconds < %.0f", opts.Namespace, opts.TokenExpiry.Seconds()),
+			fmt.Sprintf("Sonar token {{ $labels.token }} expires in less than %s", model.Duration(opts.TokenExpiry))),
+	}
+}
+
+var (
+	// componentLabels are labels identifying single component's series which are aggregated away by recording rules
+	componentLabels = []string{"component", "period_index", "period_mode", "period_parameter"}
+	// stateLabel is a label of state sets which is always kept by recording rules
+	stateLabel = "level"
+	// ratioPattern matches metrics which are averaged even though their values are integers
+	ratioPattern = regexp.MustCompile("coverage|density|rating|ratio|percent")
+)
+
+// recordings returns recording rules pre-aggregating metrics having at least MinSeries series.
+// Counters, e.g. number of bugs, are summed up, ratios and ratings are averaged
+func recordings(opts Options) []rule {
+	names := make([]string, 0, len(opts.Families))
+	for name := range opts.Families {
+		// exporter's own and per component metrics make no sense aggregated
+		if !strings.HasPrefix(name, opts.Namespace+"_") ||
+			strings.HasPrefix(name, opts.Namespace+"_exporter_") || strings.HasPrefix(name, opts.Namespace+"_component_") {
+			continue
+		}
+		if len(opts.Families[name].GetMetric()) < opts.MinSeries {
+			continue
+		}
+		// numerically encoded levels are aggregated as state sets
+		if _, found := opts.Families[name+"_"+stateLabel]; found {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recording := make([]rule, 0, len(names))
+	for _, name := range names {
+		op := "sum"
+		if ratioPattern.MatchString(name) || !integral(opts.Families[name]) {
+			op = "avg"
+		}
+		var level, grouping string
+		if len(opts.RollupLabels) > 0 {
+			by := opts.RollupLabels
+			if hasLabel(opts.Families[name], stateLabel) {
+				by = append(by[:len(by):len(by)], stateLabel)
+			}
+			level = strings.Join(opts.RollupLabels, "_")
+			grouping = "by (" + strings.Join(by, ", ") + ")"
+		} else {
+			level = "tags"
+			grouping = "without (" + strings.Join(componentLabels, ", ") + ")"
+		}
+		recording = append(recording, rule{
+			Record: fmt.Sprintf("%s:%s:%s", level, name, op),
+			Expr:   fmt.Sprintf("%s %s (%s)", op, grouping, opts.series(strings.TrimPrefix(name, opts.Namespace+"_"))),
+		})
+	}
+	return recording
+}
+
+// integral reports whether all values of the family are integers
+func integral(family *dto.MetricFamily) bool {
+	for _, m := range family.GetMetric() {
+		var val float64
+		switch {
+		case m.Gauge != nil:
+			val = m.Gauge.GetValue()
+		case m.Untyped != nil:
+			val = m.Untyped.GetValue()
+		default:
+			continue
+		}
+		if val != math.Trunc(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLabel reports whether series of the family have the label
+func hasLabel(family *dto.MetricFamily, name string) bool {
+	for _, m := range family.GetMetric() {
+		for _, l := range m.GetLabel() {
+			if l.GetName() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FetchFamilies loads metric families exposed by running exporter
+func FetchFamilies(u string) (map[string]*dto.MetricFamily, error) {
+	rs, err := http.Get(u) // nolint:gosec,noctx
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch metrics: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	if rs.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch metrics. status code %d", rs.StatusCode)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rs.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse metrics: %w", err)
+	}
+	return families, nil
+}
+
+// series returns selector of the metric with configured and provided label matchers
+func (opts Options) series(name string, matchers ...string) string {
+	if opts.Selector != "" {
+		matchers = append(matchers, opts.Selector)
+	}
+	selector := opts.Namespace + "_" + name
+	if len(matchers) > 0 {
+		selector += "{" + strings.Join(matchers, ",") + "}"
+	}
+	return selector
+}
+
+// ParseLabels parses comma separated key=value pairs
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if s == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, key=value expected", pair)
+		}
+		labels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return labels, nil
+}
@@ -0,0 +1,106 @@
+// Package summary builds concise human-readable quality summaries of Sonar projects, e.g. for chat bots
+// and release scripts
+package summary
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// metrics are measures summary is built of
+var metrics = []string{"coverage", "new_coverage", "new_bugs", "new_vulnerabilities", "new_code_smells"}
+
+// Summary is a quality summary of a project
+type Summary struct {
+	Key      string
+	Name     string
+	Analyzed time.Time
+	// Gate is a quality gate status of the project, e.g. OK or ERROR
+	Gate string
+	// Failing are quality gate conditions the project fails
+	Failing []*sonar.GateCondition
+	// Values are overall values of measures by metric key, new code values for new code metrics
+	Values map[string]string
+}
+
+// Load collects summary of the project
+func Load(client *sonar.Client, key string) (*Summary, error) {
+	component, err := client.GetComponent(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get project: %w", err)
+	}
+	status, err := client.GetProjectStatus(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get quality gate status: %w", err)
+	}
+	measures, err := client.GetMeasures(key, metrics)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get measures: %w", err)
+	}
+
+	s := &Summary{
+		Key:      component.Key,
+		Name:     component.Name,
+		Analyzed: time.Time(component.AnalysisDate),
+		Gate:     status.Status,
+		Values:   map[string]string{},
+	}
+	for _, condition := range status.Conditions {
+		if condition.Status == "ERROR" {
+			s.Failing = append(s.Failing, condition)
+		}
+	}
+	for _, m := range measures.Component.Measures {
+		if m.IsNewCode() {
+			s.Values[m.Metric] = m.NewCodeValue()
+		} else {
+			s.Values[m.Metric] = m.Value
+		}
+	}
+	return s, nil
+}
+
+// Write prints the summary in a few lines of plain text
+func (s *Summary) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", s.Name, s.Key)
+	fmt.Fprintf(&b, "Quality gate: %s", s.Gate)
+	if len(s.Failing) > 0 {
+		failing := make([]string, 0, len(s.Failing))
+		for _, c := range s.Failing {
+			failing = append(failing, fmt.Sprintf("%s %s (%s %s)", c.MetricKey, c.ActualValue, c.Comparator, c.ErrorThreshold))
+		}
+		fmt.Fprintf(&b, ", failing %s", strings.Join(failing, ", "))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Coverage: %s, new code %s\n", s.percent("coverage"), s.percent("new_coverage"))
+	fmt.Fprintf(&b, "New issues: bugs %s, vulnerabilities %s, code smells %s\n",
+		s.value("new_bugs"), s.value("new_vulnerabilities"), s.value("new_code_smells"))
+	if s.Analyzed.IsZero() {
+		b.WriteString("Analyzed: never\n")
+	} else {
+		fmt.Fprintf(&b, "Analyzed: %s\n", s.Analyzed.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// value returns value of the metric. n/a if the project lacks the measure
+func (s *Summary) value(metric string) string {
+	if val, found := s.Values[metric]; found && val != "" {
+		return val
+	}
+	return "n/a"
+}
+
+// percent returns value of the percent metric
+func (s *Summary) percent(metric string) string {
+	if val := s.value(metric); val != "n/a" {
+		return val + "%"
+	}
+	return "n/a"
+}
@@ -0,0 +1,169 @@
+// Package update checks GitHub releases for newer versions of the exporter
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// releaseURL is GitHub API endpoint of the latest release of the exporter
+	releaseURL   = "https://api.github.com/repos/avarabyeu/sonarqube-prometheus-exporter/releases/latest"
+	checkTimeout = 30 * time.Second
+	// Interval is an interval releases are checked at
+	Interval = 24 * time.Hour
+)
+
+type release struct {
+	TagName string `json:"tag_name"`
+}
+
+// Checker periodically checks the latest release of the exporter and exports whether it is newer than the running one.
+// Requests go through proxy of HTTPS_PROXY environment variable if set
+type Checker struct {
+	c       *http.Client
+	version string
+
+	available *prometheus.GaugeVec
+}
+
+// NewChecker creates checker of the running version of the exporter
+func NewChecker(version string) *Checker {
+	return &Checker{
+		c:       &http.Client{Timeout: checkTimeout},
+		version: version,
+		available: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "sonar",
+			Subsystem: "exporter",
+			Name:      "update_available",
+			Help:      "Whether a newer release of the exporter is available. Always 0 for builds of no release",
+		}, []string{"version", "latest_version"}),
+	}
+}
+
+// Run checks the latest release right away and then every Interval until done is closed. Failures are logged
+func (c *Checker) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Check(); err != nil {
+			log.Printf("Unable to check exporter updates: %v", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the latest release and updates exported availability
+func (c *Checker) Check() error {
+	latest, err := c.latest()
+	if err != nil {
+		return err
+	}
+	available := 0.0
+	if newer(latest, c.version) {
+		available = 1
+		log.Printf("Exporter %s is available, running %s", latest, c.version)
+	}
+	c.available.Reset()
+	c.available.WithLabelValues(c.version, latest).Set(available)
+	return nil
+}
+
+// latest returns version of the latest release
+func (c *Checker) latest() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to build request: %w", err)
+	}
+	rq.Header.Set("Accept", "application/vnd.github.v3+json")
+	rs, err := c.c.Do(rq)
+	if err != nil {
+		return "", fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read response: %w", err)
+	}
+	if rs.StatusCode >= 400 {
+		return "", fmt.Errorf("request failed. status code %d. Error: %s", rs.StatusCode, string(body))
+	}
+	var r release
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("unable to decode response: %w", err)
+	}
+	if r.TagName == "" {
+		return "", errors.New("no release tag")
+	}
+	return strings.TrimPrefix(r.TagName, "v"), nil
+}
+
+// newer reports whether semantic version latest is newer than current. False if either is not a version,
+// e.g. current is a commit hash of development build
+func newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses major, minor and patch of version like v1.2.3. Pre-release and build suffixes are ignored
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// Describe implements prometheus.Collector
+func (c *Checker) Describe(ch chan<- *prometheus.Desc) {
+	c.available.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Checker) Collect(ch chan<- prometheus.Metric) {
+	c.available.Collect(ch)
+}
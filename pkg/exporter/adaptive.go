@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"log"
+	"time"
+)
+
+// adapt stretches interval between collection cycles twice while Sonar is under pressure, i.e. mean latency or
+// ratio of failed API calls of the cycle exceeds the threshold, and shrinks it back once Sonar recovers
+func (c *Collector) adapt(calls, failed uint64, latency time.Duration) {
+	if c.cfg.MaxInterval <= c.cfg.ScrapeTimeout || calls == 0 {
+		return
+	}
+	mean := latency / time.Duration(calls)
+	ratio := float64(failed) / float64(calls)
+
+	interval := c.interval
+	if mean > c.cfg.LatencyThreshold || ratio > c.cfg.ErrorRatioThreshold {
+		interval *= 2
+		if interval > c.cfg.MaxInterval {
+			interval = c.cfg.MaxInterval
+		}
+		if interval != c.interval {
+			log.Printf("Sonar is under pressure (mean latency %s, failed calls %.0f%%), collection interval stretched to %s",
+				mean, ratio*100, interval)
+		}
+	} else {
+		interval /= 2
+		if interval < c.cfg.ScrapeTimeout {
+			interval = c.cfg.ScrapeTimeout
+		}
+		if interval != c.interval {
+			log.Printf("Sonar load decreased, collection interval shrunk to %s", interval)
+		}
+	}
+	c.interval = interval
+	c.self.interval.Set(interval.Seconds())
+}
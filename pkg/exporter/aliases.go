@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"fmt"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// AliasMode defines which keys series of a renamed component are exported under
+type AliasMode string
+
+const (
+	// AliasReplace exports series under the historical key only
+	AliasReplace AliasMode = "replace"
+	// AliasBoth exports series under both current and historical keys
+	AliasBoth AliasMode = "both"
+)
+
+// Alias is a historical key of a component renamed in Sonar, so series keep their component label
+// and dashboards survive migrations of project keys
+type Alias struct {
+	// Key is a historical key of the component
+	Key string `yaml:"key" json:"key"`
+	// Mode defines keys series are exported under. Empty means replace
+	Mode AliasMode `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// Aliases are aliases keyed by current key of component
+type Aliases map[string]Alias
+
+// Validate makes sure every alias has a key of its own and a known mode
+func (a Aliases) Validate() error {
+	historical := make(map[string]string, len(a))
+	for key, alias := range a {
+		switch {
+		case alias.Key == "":
+			return fmt.Errorf("historical key of component %s is required", key)
+		case alias.Key == key:
+			return fmt.Errorf("historical key of component %s is the same as the current one", key)
+		}
+		if other, found := historical[alias.Key]; found {
+			return fmt.Errorf("components %s and %s share historical key %s", other, key, alias.Key)
+		}
+		historical[alias.Key] = key
+		switch alias.Mode {
+		case "", AliasReplace, AliasBoth:
+		default:
+			return fmt.Errorf("unknown alias mode of component %s: %s", key, alias.Mode)
+		}
+	}
+	for key := range a {
+		if _, found := historical[key]; found {
+			return fmt.Errorf("historical key %s is a current key of another component", key)
+		}
+	}
+	return nil
+}
+
+// addAlias adds copies of snapshot's series labeled with historical key of the component if it has one
+func (pe *PrometheusExporter) addAlias(snapshot *componentSnapshot, key string) {
+	alias, found := pe.aliases[key]
+	if !found || snapshot.labels.dropped {
+		return
+	}
+	snapshot.alias = alias.Key
+	snapshot.aliasBoth = alias.Mode == AliasBoth
+	snapshot.aliasSeries = make([]series, len(snapshot.series))
+	// series mostly share label pairs, so each set of pairs is copied once
+	copies := map[**dto.LabelPair][]*dto.LabelPair{}
+	for i, s := range snapshot.series {
+		s.labels = withComponent(s.labels, alias.Key, copies)
+		snapshot.aliasSeries[i] = s
+	}
+}
+
+// withComponent returns a copy of label pairs with the component label replaced by the key.
+// Copies are cached by the backing array of pairs
+func withComponent(pairs []*dto.LabelPair, key string, copies map[**dto.LabelPair][]*dto.LabelPair) []*dto.LabelPair {
+	if len(pairs) == 0 {
+		return pairs
+	}
+	if cached, found := copies[&pairs[0]]; found && len(cached) == len(pairs) {
+		return cached
+	}
+	replaced := make([]*dto.LabelPair, len(pairs))
+	for i, pair := range pairs {
+		if pair.GetName() == componentLabel {
+			name := componentLabel
+			pair = &dto.LabelPair{Name: &name, Value: &key}
+		}
+		replaced[i] = pair
+	}
+	copies[&pairs[0]] = replaced
+	return replaced
+}
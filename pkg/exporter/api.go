@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// RefreshPath is a path prefix of on-demand project refresh endpoint
+const RefreshPath = "/api/v1/refresh/"
+
+var (
+	errNotInitialized = errors.New("collector is not initialized yet")
+	errNotLeader      = errors.New("instance is not a leader")
+	errAnotherShard   = errors.New("project belongs to another shard")
+)
+
+// Refresh immediately collects measures of a single project outside of schedule.
+// Returns number of exported measures
+func (c *Collector) Refresh(key string) (int, error) {
+	if len(c.metricKeys()) == 0 {
+		return 0, errNotInitialized
+	}
+	if c.cfg.Leader != nil && !c.cfg.Leader() {
+		return 0, errNotLeader
+	}
+	if quiet, _ := c.quiet(); quiet {
+		return 0, errQuietHours
+	}
+	if c.cfg.ShardCount > 1 && shardOf(key, c.cfg.ShardCount) != c.cfg.ShardIndex {
+		return 0, errAnotherShard
+	}
+	// refreshed snapshot goes through a batch, so a cycle committed meanwhile never replaces it with older measures
+	b := c.exporter.newBatch()
+	exported, err := c.collectComponent(key, b)
+	if err != nil {
+		return 0, err
+	}
+	c.exporter.apply(b)
+	return exported, nil
+}
+
+// authorized reports whether request carries the refresh token
+func (c *Collector) authorized(rq *http.Request) bool {
+	token := strings.TrimPrefix(rq.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.cfg.RefreshToken)) == 1
+}
+
+// RefreshHandler serves POST /api/v1/refresh/{projectKey} requests authorized with RefreshToken as a bearer token.
+// Responds with 404 unless RefreshToken is set
+func (c *Collector) RefreshHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		if c.cfg.RefreshToken == "" {
+			http.NotFound(w, rq)
+			return
+		}
+		if !c.authorized(rq) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if rq.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(rq.URL.Path, RefreshPath)
+		if key == "" || strings.Contains(key, "/") {
+			http.Error(w, "project key is required", http.StatusBadRequest)
+			return
+		}
+
+		exported, err := c.Refresh(key)
+		switch {
+		case errors.Is(err, errNotInitialized), errors.Is(err, errNotLeader), errors.Is(err, errQuietHours):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, errAnotherShard):
+			http.Error(w, err.Error(), http.StatusMisdirectedRequest)
+			return
+		case errors.Is(err, errOptedOut):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, sonar.ErrNotFound):
+			http.Error(w, fmt.Sprintf("project not found: %v", err), http.StatusNotFound)
+			return
+		case err != nil:
+			log.Printf("Unable to refresh component %s: %v", key, err)
+			http.Error(w, fmt.Sprintf("unable to refresh project: %v", err), http.StatusBadGateway)
+			return
+		}
+		log.Printf("Component %s refreshed on demand", key)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"component": key, "measures": exported}); err != nil {
+			log.Print(err)
+		}
+	})
+}
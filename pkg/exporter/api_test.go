@@ -0,0 +1,111 @@
+package exporter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		auth   string
+		key    string
+		status int
+	}{
+		{name: "disabled", key: "p-00", status: http.StatusNotFound},
+		{name: "no token", token: "secret", key: "p-00", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", auth: "Bearer other", key: "p-00", status: http.StatusUnauthorized},
+		{name: "refreshed", token: "secret", auth: "Bearer secret", key: "p-00", status: http.StatusOK},
+		{name: "opted out", token: "secret", auth: "Bearer secret", key: "p-01", status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMock(2)
+			mock.GetComponentFunc = func(key string) (*sonar.Component, error) {
+				if key == "p-01" {
+					return component(key, "no-metrics"), nil
+				}
+				return component(key), nil
+			}
+			exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{})
+			collector := exporter.NewCollector(mock, exp, exporter.Config{RefreshToken: tt.token, OptOutTag: "no-metrics"})
+			if err := collector.RunOnce(); err != nil {
+				t.Fatal(err)
+			}
+
+			rq := httptest.NewRequest(http.MethodPost, exporter.RefreshPath+tt.key, nil)
+			if tt.auth != "" {
+				rq.Header.Set("Authorization", tt.auth)
+			}
+			rs := httptest.NewRecorder()
+			collector.RefreshHandler().ServeHTTP(rs, rq)
+			if rs.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rs.Code, tt.status, rs.Body.String())
+			}
+		})
+	}
+}
+
+// TestRefreshDuringCycle refreshes a component after the running cycle has collected it,
+// so the cycle must not replace refreshed measures with its older ones once committed
+func TestRefreshDuringCycle(t *testing.T) {
+	var bugs int32 = 1
+	collecting, release := make(chan struct{}), make(chan struct{})
+	var blocking int32
+
+	mock := newMock(2)
+	mock.GetMeasuresFunc = func(key string, _ []string) (*sonar.Measures, error) {
+		if key == "p-01" && atomic.CompareAndSwapInt32(&blocking, 1, 0) {
+			close(collecting)
+			<-release
+		}
+		if key == "p-00" {
+			return measures(key, "bugs", strconv.Itoa(int(atomic.LoadInt32(&bugs)))), nil
+		}
+		return measures(key, "bugs", "0"), nil
+	}
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{})
+	collector := exporter.NewCollector(mock, exp, exporter.Config{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&blocking, 1)
+	done := make(chan error)
+	go func() { done <- collector.RunOnce() }()
+	<-collecting
+	atomic.StoreInt32(&bugs, 2)
+	if _, err := collector.Refresh("p-00"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "sonar_bugs" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if labels(m)["component"] == "p-00" && m.GetGauge().GetValue() != 2 {
+				t.Errorf("refreshed component has %v bugs, want 2", m.GetGauge().GetValue())
+			}
+		}
+	}
+}
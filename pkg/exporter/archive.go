@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ArchiveSnapshot is a snapshot of series exported by the end of a cycle
+type ArchiveSnapshot struct {
+	Collected time.Time       `json:"collected"`
+	Series    []ArchiveSeries `json:"series"`
+}
+
+// ArchiveSeries is a series of snapshot
+type ArchiveSeries struct {
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+const (
+	// archiveLayout is a layout of time snapshots are named after
+	archiveLayout = "2006-01-02T15-04-05Z"
+	// archiveSuffix is an extension of snapshot names
+	archiveSuffix = ".json.gz"
+)
+
+// archiveName returns name of snapshot collected at the time, so snapshots are sorted by time
+func archiveName(now time.Time) string {
+	return now.UTC().Format(archiveLayout) + archiveSuffix
+}
+
+// IsArchiveName reports whether name is a name of snapshot, e.g. 2026-10-15T05-30-00Z.json.gz
+func IsArchiveName(name string) bool {
+	if !strings.HasSuffix(name, archiveSuffix) {
+		return false
+	}
+	_, err := time.Parse(archiveLayout, strings.TrimSuffix(name, archiveSuffix))
+	return err == nil
+}
+
+// archive builds gzipped JSON snapshot of series exported by the exporter
+func (pe *PrometheusExporter) archive(now time.Time) ([]byte, error) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(pe); err != nil {
+		return nil, fmt.Errorf("unable to register exporter: %w", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("unable to gather series: %w", err)
+	}
+
+	snapshot := ArchiveSnapshot{Collected: now.UTC(), Series: []ArchiveSeries{}}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			s := ArchiveSeries{Metric: family.GetName(), Value: seriesValue(m)}
+			if len(m.GetLabel()) > 0 {
+				s.Labels = make(map[string]string, len(m.GetLabel()))
+				for _, pair := range m.GetLabel() {
+					s.Labels[pair.GetName()] = pair.GetValue()
+				}
+			}
+			snapshot.Series = append(snapshot.Series, s)
+		}
+	}
+
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return nil, fmt.Errorf("unable to marshal snapshot: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress snapshot: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// ReadArchive reads gzipped JSON snapshot
+func ReadArchive(r io.Reader) (*ArchiveSnapshot, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress snapshot: %w", err)
+	}
+	var snapshot ArchiveSnapshot
+	if err := json.NewDecoder(gr).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// seriesValue returns value of gauge, counter or untyped series
+func seriesValue(m *dto.Metric) float64 {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	default:
+		return m.GetUntyped().GetValue()
+	}
+}
+
+// archive uploads snapshot of exported series and logs failures
+func (c *Collector) archive(now time.Time) {
+	content, err := c.exporter.archive(now)
+	if err == nil {
+		err = c.cfg.Archive(archiveName(now), content)
+	}
+	if err != nil {
+		log.Printf("Unable to archive snapshot: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherLabelValue replaces label values beyond the limit
+const otherLabelValue = "other"
+
+// labelLimiter caps number of distinct values of labels converted from tags. Values are admitted
+// first come first served and released once not reported for metricTTL cycles.
+// Values beyond the limit are replaced with otherLabelValue
+type labelLimiter struct {
+	limits map[string]int
+
+	mut sync.Mutex
+	// values are admitted values by label along with the cycle they were reported last time in
+	values map[string]map[string]uint64
+
+	// overflows counts label values replaced due to the limit
+	overflows *prometheus.CounterVec
+}
+
+func newLabelLimiter(limits map[string]int) *labelLimiter {
+	values := make(map[string]map[string]uint64, len(limits))
+	for label := range limits {
+		values[label] = map[string]uint64{}
+	}
+	return &labelLimiter{
+		limits: limits,
+		values: values,
+		overflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "label_overflows_total",
+			Help:      "Number of component reports whose label value was replaced with 'other' due to the limit of label values",
+		}, []string{"label"}),
+	}
+}
+
+// limit replaces values beyond the limit in place and marks the rest as reported in the cycle
+func (l *labelLimiter) limit(labels prometheus.Labels, cycle uint64) {
+	if len(l.limits) == 0 {
+		return
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for label, max := range l.limits {
+		val, found := labels[label]
+		if !found {
+			continue
+		}
+		admitted := l.values[label]
+		if _, found := admitted[val]; found || len(admitted) < max {
+			admitted[val] = cycle
+			continue
+		}
+		labels[label] = otherLabelValue
+		l.overflows.WithLabelValues(label).Inc()
+	}
+}
+
+// expire releases values not reported for ttl cycles
+func (l *labelLimiter) expire(cycle, ttl uint64) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for _, admitted := range l.values {
+		for val, reported := range admitted {
+			if cycle-reported > ttl {
+				delete(admitted, val)
+			}
+		}
+	}
+}
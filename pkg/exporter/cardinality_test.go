@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestLabelLimiter(t *testing.T) {
+	l := newLabelLimiter(map[string]int{"team": 2})
+	limit := func(team string, cycle uint64) string {
+		labels := prometheus.Labels{"team": team, "env": "prod"}
+		l.limit(labels, cycle)
+		if labels["env"] != "prod" {
+			t.Errorf("unlimited label replaced with %q", labels["env"])
+		}
+		return labels["team"]
+	}
+
+	for i, tt := range []struct{ team, want string }{
+		{team: "payments", want: "payments"},
+		{team: "web", want: "web"},
+		{team: "mobile", want: otherLabelValue},
+		{team: "payments", want: "payments"},
+		{team: "data", want: otherLabelValue},
+	} {
+		if got := limit(tt.team, 1); got != tt.want {
+			t.Errorf("report %d of team %s labeled with %s, want %s", i, tt.team, got, tt.want)
+		}
+	}
+	if overflows := testutil.ToFloat64(l.overflows.WithLabelValues("team")); overflows != 2 {
+		t.Errorf("%v overflows, want 2", overflows)
+	}
+
+	// web is not reported since the first cycle, so it is released for mobile
+	limit("payments", 3)
+	l.expire(3, 1)
+	if got := limit("mobile", 3); got != "mobile" {
+		t.Errorf("mobile labeled with %s once web is released, want mobile", got)
+	}
+	if got := limit("web", 3); got != otherLabelValue {
+		t.Errorf("web labeled with %s after its value is released, want other", got)
+	}
+	if overflows := testutil.ToFloat64(l.overflows.WithLabelValues("team")); overflows != 3 {
+		t.Errorf("%v overflows, want 3", overflows)
+	}
+}
+
+func TestLabelLimitsOfExportedSeries(t *testing.T) {
+	pe := NewPrometheusExporter(ExporterConfig{LabelSeparator: "#", LabelLimits: map[string]int{"team": 2}})
+	pe.registerMetrics([]*sonar.Metric{{Key: "bugs", Type: "INT"}})
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("p-%d", i)
+		c := &sonar.Component{Tags: []string{fmt.Sprintf("team#t-%d", i)}}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		m.Component.Measures = []*sonar.Measure{{Metric: "bugs", Value: "1"}}
+		pe.Report(c, m)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(pe)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams := map[string]int{}
+	var overflows float64
+	for _, family := range families {
+		switch family.GetName() {
+		case "sonar_bugs":
+			for _, m := range family.GetMetric() {
+				for _, pair := range m.GetLabel() {
+					if pair.GetName() == "team" {
+						teams[pair.GetValue()]++
+					}
+				}
+			}
+		case "sonar_exporter_label_overflows_total":
+			overflows = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if overflows != 2 {
+		t.Errorf("%v overflows of team, want 2", overflows)
+	}
+	if len(teams) != 3 || teams["t-0"] != 1 || teams["t-1"] != 1 || teams[otherLabelValue] != 2 {
+		t.Errorf("series by team %v, want t-0, t-1 and 2 of other", teams)
+	}
+}
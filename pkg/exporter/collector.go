@@ -0,0 +1,526 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// Config holds collection settings
+type Config struct {
+	// ScrapeTimeout is a delay between two collection cycles
+	ScrapeTimeout time.Duration
+	// MaxInterval is a max delay between collection cycles the delay is stretched to while Sonar is under pressure,
+	// see LatencyThreshold and ErrorRatioThreshold. Zero disables adaptive scheduling
+	MaxInterval time.Duration
+	// LatencyThreshold is a mean latency of Sonar API calls of a cycle above which Sonar is considered under pressure
+	LatencyThreshold time.Duration
+	// ErrorRatioThreshold is a ratio of failed Sonar API calls of a cycle above which Sonar is considered under pressure
+	ErrorRatioThreshold float64
+	// InitialDelay is a delay before the first collection cycle
+	InitialDelay time.Duration
+	// OnError defines behavior in case of failed collection cycles
+	OnError ErrorPolicy
+	// MaxFailures is a number of consecutive failed cycles after which collection stops.
+	// Zero means one failure for 'exit' policy and no limit for 'backoff' policy
+	MaxFailures int
+	// MinSuccessRatio is a share of components which must be collected successfully during a cycle
+	// for the collector to be ready, see ReadyHandler
+	MinSuccessRatio float64
+	// RequestBudget is a max number of Sonar API requests of a collection cycle. Once the budget is spent,
+	// the rest of components keep measures of previous cycles. Zero means no limit
+	RequestBudget int
+	// CycleDeadline is a max duration of a collection cycle. Once passed, components not collected yet are left
+	// for the next cycle, which collects them first. Zero means no limit
+	CycleDeadline time.Duration
+	// QuietHours is a daily window collection is paused during, e.g. Sonar's nightly housekeeping. Nil means no pause
+	QuietHours *QuietHours
+	// Metrics are keys of collected metrics. If provided, metrics are not discovered with api/metrics/search,
+	// which may be forbidden to the user, and their definitions are learned from measures responses instead
+	Metrics []string
+	// Concurrency is a max number of components collected in parallel
+	Concurrency int
+	// Leader reports whether this instance is allowed to collect measures.
+	// Used to run several replicas where only one of them talks to Sonar. Nil means always
+	Leader func() bool
+	// ShardCount is a number of instances projects are split between. Zero or one disables sharding
+	ShardCount int
+	// ShardIndex is an index of shard this instance collects, from 0 to ShardCount-1
+	ShardIndex int
+	// Slices is a number of cycles collection of components is spread across. Every cycle collects
+	// a single slice in round-robin, so each component is collected once per Slices cycles. Zero or one disables slicing
+	Slices int
+	// ProjectFilter is a filter of projects collected, e.g. alert_status = ERROR, in syntax of
+	// api/components/search_projects. Empty means all projects
+	ProjectFilter string
+	// ProjectSort is a field projects are collected in order of, e.g. ncloc. Prefixed with - for descending order
+	ProjectSort string
+	// ProjectPriority are priorities projects are collected in order of during a cycle, see ParseProjectPriority.
+	// Empty keeps order projects are listed in
+	ProjectPriority []string
+	// OptOutTag is a Sonar tag excluding tagged projects from export, so project owners may opt out themselves.
+	// Empty disables the opt-out
+	OptOutTag string
+	// FileSDPath is a file collected projects are written to as Prometheus file_sd targets. Empty disables the output
+	FileSDPath string
+	// ReportPath is a file measures of exported projects are written to as CSV every cycle. {date} placeholder
+	// is replaced with the date of the cycle. Empty disables the report
+	ReportPath string
+	// ReportUpload uploads CSV report every cycle, e.g. to object storage. Nil disables the upload
+	ReportUpload func(name string, content []byte) error
+	// Archive uploads gzipped JSON snapshot of exported series every cycle, e.g. to object storage.
+	// Nil disables snapshots
+	Archive func(name string, content []byte) error
+	// FileSDTarget is an address of the exporter Prometheus scrapes file_sd targets at
+	FileSDTarget string
+	// FileSDScheme and FileSDMetricsPath are scheme and path of metrics endpoint Prometheus scrapes file_sd targets at,
+	// e.g. behind a reverse proxy. Empty keep Prometheus defaults
+	FileSDScheme      string
+	FileSDMetricsPath string
+	// Notify sends notifications about failing collection, e.g. to a chat. Nil disables notifications
+	Notify func(message string)
+	// NotifyThreshold is a number of consecutive failed cycles after which notification is sent.
+	// Authentication failures are notified immediately
+	NotifyThreshold int
+	// CustomMeasures enables collection of custom (manual) measures exported as regular ones
+	CustomMeasures bool
+	// ProjectLinks enables export of project links
+	ProjectLinks bool
+	// GateConditions enables export of thresholds and actual values of quality gate conditions
+	GateConditions bool
+	// GateInfo enables export of quality gates assigned to projects
+	GateInfo bool
+	// PullRequests enables export of number of pull requests failing quality gate
+	PullRequests bool
+	// Portfolios enables export of portfolio ratings every cycle
+	Portfolios bool
+	// Preflight enables probing of API endpoints required by enabled features on start
+	Preflight bool
+	// ServerInfo enables export of Sonar version and edition fetched once on start
+	ServerInfo bool
+	// CheckTokens enables check of user tokens expiration every cycle
+	CheckTokens bool
+	// TokenName is a name of the token exporter uses. Empty means all tokens of the user are checked
+	TokenName string
+	// TokenExpiryWarning is a time before token expiration starting from which warnings are logged
+	TokenExpiryWarning time.Duration
+
+	// IssueAgeBuckets are ages in days open issues are counted by. Empty disables the count
+	IssueAgeBuckets []int
+	// ResolvedIssues enables count of issues resolved as false positive or accepted
+	ResolvedIssues bool
+	// TopRules is a number of rules with the most open issues exported per component. Zero disables the export
+	TopRules int
+	// RefreshToken is a bearer token required by on-demand refresh, see RefreshHandler. Empty disables the refresh
+	RefreshToken string
+	// Merges are keys of projects merged into logical components by their keys. Projects are exported as well
+	Merges Merges
+}
+
+// Collector periodically collects measures of all Sonar projects
+// and reports them to Prometheus. Collector itself is a prometheus.Collector
+// exposing exporter's own metrics
+type Collector struct {
+	sonar    SonarAPI
+	exporter *PrometheusExporter
+	cfg      Config
+	self     *selfMetrics
+
+	metricsMut sync.RWMutex
+	metrics    []string
+	// failures is a number of consecutive failed cycles
+	failures int
+	// notified is true if current failures have been notified about
+	notified bool
+	// maintenance is a number of consecutive cycles skipped while Sonar is under maintenance
+	maintenance int
+	// inFlight is a number of components being collected. Accessed atomically
+	inFlight int64
+	// pending are keys of components left uncollected by the previous cycle due to CycleDeadline
+	pending []string
+	// portfolios are keys of portfolios listed by the previous cycle, see collectPortfolios
+	portfolios map[string]struct{}
+	// tick is a number of executed collection cycles defining collected slice
+	tick uint64
+	// interval is a current delay between collection cycles, see adapt
+	interval time.Duration
+
+	readinessMut sync.RWMutex
+	// successRatio is a share of components collected successfully during the last cycle
+	successRatio float64
+	// collected is true once the first collection cycle finished
+	collected bool
+
+	// components caches component metadata from previous cycles
+	componentsMut sync.Mutex
+	components    map[string]*sonar.Component
+
+	// optional are optional collectors by whether they are enabled, see optionalFailed
+	optionalMut sync.RWMutex
+	optional    map[string]bool
+
+	// up are results of the last collection of components by key
+	upMut sync.Mutex
+	up    map[string]bool
+
+	// snapshots caches open issues of components by analysis, see openIssues
+	snapshotsMut sync.Mutex
+	snapshots    map[string]*issuesSnapshot
+
+	// mergedInto are keys of logical components by keys of projects merged into them
+	mergedInto map[string]string
+	// merged are the last collected projects merged into logical components by key, see reportMerged
+	mergedMut sync.Mutex
+	merged    map[string]*mergedMember
+}
+
+// NewCollector creates new collector
+func NewCollector(client SonarAPI, exp *PrometheusExporter, cfg Config) *Collector {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.OnError == "" {
+		cfg.OnError = ErrorPolicyContinue
+	}
+	if cfg.OnError == ErrorPolicyExit && cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 1
+	}
+	if cfg.NotifyThreshold <= 0 {
+		cfg.NotifyThreshold = 1
+	}
+	c := &Collector{
+		sonar:      client,
+		exporter:   exp,
+		cfg:        cfg,
+		self:       newSelfMetrics(),
+		components: map[string]*sonar.Component{},
+		snapshots:  map[string]*issuesSnapshot{},
+		up:         map[string]bool{},
+		interval:   cfg.ScrapeTimeout,
+		mergedInto: map[string]string{},
+		merged:     map[string]*mergedMember{},
+	}
+	for key, projects := range cfg.Merges {
+		for _, project := range projects {
+			c.mergedInto[project] = key
+		}
+	}
+	c.self.interval.Set(cfg.ScrapeTimeout.Seconds())
+	c.initOptional()
+	if cfg.Leader == nil {
+		c.self.leader.Set(1)
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.self.Describe(ch)
+	ch <- componentUpDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	active := c.sonar.URL()
+	for _, u := range c.sonar.URLs() {
+		var inUse float64
+		if u == active {
+			inUse = 1
+		}
+		c.self.sonarURL.WithLabelValues(u).Set(inUse)
+	}
+	c.self.Collect(ch)
+	c.collectUp(ch)
+}
+
+// Run initializes metrics and starts collection. Blocks until done is closed
+// or error policy stops collection, in which case returns the cause
+func (c *Collector) Run(done <-chan struct{}) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	return schedule(done, c.cfg.InitialDelay, c.cycle)
+}
+
+// RunOnce initializes metrics and executes single collection cycle
+func (c *Collector) RunOnce() error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	return c.collect()
+}
+
+// init loads metric definitions
+func (c *Collector) init() error {
+	var metrics []string
+	if len(c.cfg.Metrics) > 0 {
+		// definitions are learned from measures responses
+		log.Printf("Metrics discovery is skipped, %d metrics are collected", len(c.cfg.Metrics))
+		metrics = c.cfg.Metrics
+	} else {
+		allMetrics, err := c.sonar.GetMetrics()
+		if err != nil {
+			return fmt.Errorf("unable to get metrics: %w", err)
+		}
+		metrics = c.exporter.registerMetrics(allMetrics)
+	}
+
+	c.metricsMut.Lock()
+	c.metrics = metrics
+	c.metricsMut.Unlock()
+
+	if c.cfg.ServerInfo {
+		// server info is informational only, so collection starts regardless
+		if info, err := c.sonar.GetServerInfo(); err != nil {
+			log.Printf("Unable to get server info: %v", err)
+		} else {
+			c.self.serverInfo.WithLabelValues(info.Version, info.Edition).Set(1)
+		}
+	}
+	if c.cfg.Preflight {
+		c.preflight()
+	}
+	return nil
+}
+
+// metricKeys returns keys of collected metrics. Empty until collector is initialized
+func (c *Collector) metricKeys() []string {
+	c.metricsMut.RLock()
+	defer c.metricsMut.RUnlock()
+	return c.metrics
+}
+
+// cycleStats accumulates results of a single collection cycle. Accessed atomically
+type cycleStats struct {
+	scraped  int64
+	skipped  int64
+	failed   int64
+	measures int64
+}
+
+// collect executes single collection cycle
+func (c *Collector) collect() error {
+	started := time.Now()
+	requests, failures, latency := c.sonar.Requests(), c.sonar.Failures(), c.sonar.Latency()
+	stats := &cycleStats{}
+	defer func() {
+		apiCalls := c.sonar.Requests() - requests
+		c.report(stats, time.Since(started), apiCalls)
+		c.adapt(apiCalls, c.sonar.Failures()-failures, c.sonar.Latency()-latency)
+	}()
+
+	if c.cfg.RequestBudget > 0 {
+		c.sonar.SetBudget(int64(c.cfg.RequestBudget))
+		defer c.sonar.SetBudget(-1)
+	}
+	if c.optionalEnabled(optionalTokens) {
+		c.checkTokens()
+	}
+	if c.optionalEnabled(optionalPortfolios) {
+		c.collectPortfolios()
+	}
+
+	components, err := c.listComponents()
+	if err != nil {
+		c.updateReadiness(0)
+		return fmt.Errorf("unable to get components: %w", err)
+	}
+	components = c.selectComponents(components)
+	keys := make(map[string]struct{}, len(components))
+	for _, cInfo := range components {
+		keys[cInfo.Key] = struct{}{}
+	}
+	// components of other slices keep measures of previous cycles
+	sliced := c.sliceComponents(components)
+	c.prioritizeComponents(sliced)
+	sliced = c.rollOver(components, sliced)
+	c.tick++
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.cfg.Concurrency)
+	b := c.exporter.newBatch()
+	// exhausted is set to 1 once request budget is spent. Accessed atomically
+	var exhausted int32
+	var optedOutMut sync.Mutex
+	var optedOut []string
+	c.self.queued.Set(float64(len(sliced)))
+	for i, cInfo := range sliced {
+		sem <- struct{}{}
+		if atomic.LoadInt32(&exhausted) == 1 {
+			<-sem
+			break
+		}
+		// at least one component is collected, so cycles make progress even if listing exceeds the deadline
+		if c.cfg.CycleDeadline > 0 && i > 0 && time.Since(started) >= c.cfg.CycleDeadline {
+			<-sem
+			for _, rest := range sliced[i:] {
+				c.pending = append(c.pending, rest.Key)
+			}
+			break
+		}
+		wg.Add(1)
+		c.self.queued.Dec()
+		c.trackInFlight(1)
+		go func(key string) {
+			defer func() {
+				c.trackInFlight(-1)
+				<-sem
+				wg.Done()
+			}()
+			exported, err := c.collectComponent(key, b)
+			switch {
+			case errors.Is(err, sonar.ErrBudgetExhausted):
+				atomic.StoreInt32(&exhausted, 1)
+			case errors.Is(err, errOptedOut):
+				optedOutMut.Lock()
+				optedOut = append(optedOut, key)
+				optedOutMut.Unlock()
+				atomic.AddInt64(&stats.skipped, 1)
+			case err != nil:
+				log.Printf("Unable to collect component %s: %v", key, err)
+				atomic.AddInt64(&stats.failed, 1)
+				c.setUp(key, false)
+			case exported == 0:
+				atomic.AddInt64(&stats.skipped, 1)
+				c.setUp(key, true)
+			default:
+				c.setUp(key, true)
+				atomic.AddInt64(&stats.scraped, 1)
+				atomic.AddInt64(&stats.measures, int64(exported))
+			}
+		}(cInfo.Key)
+	}
+	c.self.queued.Set(0)
+	wg.Wait()
+	if atomic.LoadInt32(&exhausted) == 1 {
+		log.Printf("Request budget of %d is exhausted, collection cycle aborted", c.cfg.RequestBudget)
+		c.self.budgetExhausted.Inc()
+	}
+	if len(c.pending) > 0 {
+		log.Printf("Cycle deadline of %s is exceeded, %d components are left for the next cycle", c.cfg.CycleDeadline, len(c.pending))
+		c.self.deadlineExceeded.Inc()
+	}
+
+	// series of opted out components are dropped right away instead of expiring
+	for _, key := range optedOut {
+		delete(keys, key)
+	}
+	c.reportMerged(b, keys)
+	c.exporter.commit(b, keys)
+	c.updateReadiness(stats.successRatio())
+	c.retainComponents(keys)
+	c.retainUp(keys)
+	if c.cfg.FileSDPath != "" {
+		if err := c.writeFileSD(); err != nil {
+			log.Printf("Unable to write file_sd targets: %v", err)
+		}
+	}
+	if c.cfg.ReportPath != "" || c.cfg.ReportUpload != nil {
+		c.writeReport(time.Now())
+	}
+	if c.cfg.Archive != nil {
+		c.archive(time.Now())
+	}
+
+	if stats.failed > 0 && stats.scraped+stats.skipped == 0 {
+		return fmt.Errorf("all %d components failed", stats.failed)
+	}
+	return nil
+}
+
+// trackInFlight accounts start (delta 1) or end (delta -1) of component collection
+func (c *Collector) trackInFlight(delta int64) {
+	n := atomic.AddInt64(&c.inFlight, delta)
+	c.self.inFlight.Set(float64(n))
+	c.self.workerUtilization.Set(float64(n) / float64(c.cfg.Concurrency))
+}
+
+// report logs cycle summary and updates exporter's metrics
+func (c *Collector) report(stats *cycleStats, duration time.Duration, apiCalls uint64) {
+	log.Printf("Collection cycle finished: scraped=%d skipped=%d failed=%d duration=%s api_calls=%d measures=%d",
+		stats.scraped, stats.skipped, stats.failed, duration, apiCalls, stats.measures)
+
+	c.self.cycleDuration.Set(duration.Seconds())
+	c.self.cycleProjects.WithLabelValues("scraped").Set(float64(stats.scraped))
+	c.self.cycleProjects.WithLabelValues("skipped").Set(float64(stats.skipped))
+	c.self.cycleProjects.WithLabelValues("failed").Set(float64(stats.failed))
+	c.self.cycleAPICalls.Set(float64(apiCalls))
+	c.self.cycleMeasures.Set(float64(stats.measures))
+}
+
+// collectComponent collects component's measures and reports them to r.
+// Returns number of exported measures
+func (c *Collector) collectComponent(key string, r reporter) (int, error) {
+	component, err := c.getComponent(key)
+	if err != nil {
+		return 0, err
+	}
+	if c.optedOut(component) {
+		return 0, errOptedOut
+	}
+	measures, err := c.sonar.GetMeasures(key, c.metricKeys())
+	if err != nil {
+		return 0, err
+	}
+	if len(c.cfg.Metrics) > 0 {
+		c.exporter.learnMetrics(measures.Metrics)
+	}
+	c.mergeCustomMeasures(key, measures)
+	c.keepMerged(component, measures)
+	extras := append(c.collectIssues(key), c.collectLinks(key)...)
+	extras = append(extras, c.collectPullRequests(key)...)
+	extras = append(extras, c.collectGateConditions(key)...)
+	extras = append(extras, c.collectGateInfo(key)...)
+	return r.report(component, measures, extras), nil
+}
+
+// getComponent loads component metadata. In case of transient failure, e.g. a timeout, falls back to
+// metadata cached in previous cycles so component's measures are still reported. Components deleted
+// or no longer accessible are not reported with stale metadata
+func (c *Collector) getComponent(key string) (*sonar.Component, error) {
+	component, err := c.sonar.GetComponent(key)
+
+	c.componentsMut.Lock()
+	defer c.componentsMut.Unlock()
+	if err != nil {
+		if errors.Is(err, sonar.ErrNotFound) {
+			delete(c.components, key)
+		}
+		cached, found := c.components[key]
+		if !found || !sonar.IsTransient(err) {
+			return nil, err
+		}
+		log.Printf("Unable to get component %s, using cached one: %v", key, err)
+		return cached, nil
+	}
+	c.components[key] = component
+	return component, nil
+}
+
+// retainComponents drops cached metadata of all components except provided ones
+func (c *Collector) retainComponents(keys map[string]struct{}) {
+	c.componentsMut.Lock()
+	defer c.componentsMut.Unlock()
+
+	for key := range c.components {
+		if _, found := keys[key]; !found {
+			delete(c.components, key)
+		}
+	}
+
+	c.snapshotsMut.Lock()
+	defer c.snapshotsMut.Unlock()
+	for key := range c.snapshots {
+		if _, found := keys[key]; !found {
+			delete(c.snapshots, key)
+		}
+	}
+}
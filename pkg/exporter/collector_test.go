@@ -0,0 +1,317 @@
+package exporter_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonartest"
+)
+
+// collect runs a collection cycle of the mock and returns series of sonar_bugs by component
+func collect(t *testing.T, mock *sonartest.MockAPI, cfg exporter.Config) map[string]*dto.Metric {
+	t.Helper()
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{LabelSeparator: "#"})
+	collector := exporter.NewCollector(mock, exp, cfg)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	return bugs(t, reg)
+}
+
+// bugs gathers series of sonar_bugs by component
+func bugs(t *testing.T, reg *prometheus.Registry) map[string]*dto.Metric {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, families)
+	res := map[string]*dto.Metric{}
+	for _, family := range families {
+		if family.GetName() == "sonar_bugs" {
+			for _, m := range family.GetMetric() {
+				res[labels(m)["component"]] = m
+			}
+		}
+	}
+	return res
+}
+
+func TestCollectExportsMeasures(t *testing.T) {
+	series := collect(t, newMock(3), exporter.Config{Concurrency: 2})
+	if len(series) != 3 {
+		t.Fatalf("%d series of sonar_bugs, want 3", len(series))
+	}
+	if team := labels(series["p-02"])["team"]; team != "p-02" {
+		t.Errorf("p-02 is labeled with team %q, want p-02", team)
+	}
+}
+
+func TestOptedOutComponentIsSkipped(t *testing.T) {
+	mock := newMock(2)
+	mock.GetComponentFunc = func(key string) (*sonar.Component, error) {
+		if key == "p-01" {
+			return component(key, "team#"+key, "no-metrics"), nil
+		}
+		return component(key, "team#"+key), nil
+	}
+	series := collect(t, mock, exporter.Config{OptOutTag: "no-metrics"})
+	if _, found := series["p-01"]; found || len(series) != 1 {
+		t.Errorf("exported components %v, want p-00 only", series)
+	}
+	if calls := mock.Calls("GetMeasures"); calls != 1 {
+		t.Errorf("measures requested %d times, want once", calls)
+	}
+}
+
+func TestFailedComponentKeepsOthers(t *testing.T) {
+	mock := newMock(3)
+	getMeasures := mock.GetMeasuresFunc
+	mock.GetMeasuresFunc = func(key string, metrics []string) (*sonar.Measures, error) {
+		if key == "p-01" {
+			return nil, &sonar.APIError{StatusCode: http.StatusInternalServerError}
+		}
+		return getMeasures(key, metrics)
+	}
+	series := collect(t, mock, exporter.Config{})
+	if _, found := series["p-01"]; found || len(series) != 2 {
+		t.Errorf("exported components %v, want p-00 and p-02", series)
+	}
+}
+
+func TestRemovedComponentIsDropped(t *testing.T) {
+	mock := newMock(2)
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{LabelSeparator: "#"})
+	collector := exporter.NewCollector(mock, exp, exporter.Config{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.GetComponentsFunc = func() ([]*sonar.ComponentInfo, error) {
+		return []*sonar.ComponentInfo{{Key: "p-00", Name: "p-00", Qualifier: "TRK"}}, nil
+	}
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if series := bugs(t, reg); len(series) != 1 || series["p-00"] == nil {
+		t.Errorf("exported components %v, want p-00 only", series)
+	}
+}
+
+func TestComponentMetadataFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		fallback bool
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, fallback: true},
+		{name: "throttled", status: http.StatusTooManyRequests, fallback: true},
+		{name: "deleted", status: http.StatusNotFound},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMock(1)
+			exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{})
+			collector := exporter.NewCollector(mock, exp, exporter.Config{})
+			if err := collector.RunOnce(); err != nil {
+				t.Fatal(err)
+			}
+
+			mock.GetComponentFunc = func(string) (*sonar.Component, error) {
+				return nil, &sonar.APIError{StatusCode: tt.status}
+			}
+			before := mock.Calls("GetMeasures")
+			err := collector.RunOnce()
+			collected := mock.Calls("GetMeasures") > before
+			if collected != tt.fallback {
+				t.Errorf("collected with cached metadata: %v, want %v", collected, tt.fallback)
+			}
+			if tt.fallback && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.fallback && err == nil {
+				t.Error("cycle succeeded without metadata of the only component")
+			}
+		})
+	}
+}
+
+func TestReportUpload(t *testing.T) {
+	var name string
+	var content []byte
+	cfg := exporter.Config{ReportUpload: func(n string, c []byte) error {
+		name, content = n, c
+		return nil
+	}}
+	collect(t, newMock(2), cfg)
+	if !strings.HasPrefix(name, "sonar-") || !strings.HasSuffix(name, ".csv") {
+		t.Errorf("report uploaded as %q", name)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][0] != "p-00" || rows[2][0] != "p-01" {
+		t.Errorf("unexpected report rows %v", rows)
+	}
+}
+
+func TestFailedPortfolioKeepsRatings(t *testing.T) {
+	portfolios := []*sonar.ComponentInfo{{Key: "all", Qualifier: "VW"}, {Key: "web", Qualifier: "VW"}}
+	var failing string
+	mock := newMock(1)
+	mock.GetPortfoliosFunc = func() ([]*sonar.ComponentInfo, error) {
+		return portfolios, nil
+	}
+	getMeasures := mock.GetMeasuresFunc
+	mock.GetMeasuresFunc = func(key string, metrics []string) (*sonar.Measures, error) {
+		switch {
+		case key == failing:
+			return nil, &sonar.APIError{StatusCode: http.StatusInternalServerError}
+		case key == "all" || key == "web":
+			return measures(key, "reliability_rating", "2", "reliability_rating_distribution", "1=1;4=1"), nil
+		}
+		return getMeasures(key, metrics)
+	}
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{})
+	collector := exporter.NewCollector(mock, exp, exporter.Config{Portfolios: true})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+	// ratings gathers ratings and the worst project ratings by portfolio
+	ratings := func() map[string]float64 {
+		if err := collector.RunOnce(); err != nil {
+			t.Fatal(err)
+		}
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := map[string]float64{}
+		for _, family := range families {
+			if !strings.HasPrefix(family.GetName(), "sonar_portfolio_") {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				res[family.GetName()+"/"+labels(m)["portfolio"]] = m.GetGauge().GetValue()
+			}
+		}
+		return res
+	}
+
+	if got := ratings(); len(got) != 4 || got["sonar_portfolio_worst_project_rating/web"] != 4 {
+		t.Fatalf("unexpected ratings %v", got)
+	}
+	failing = "web"
+	if got := ratings(); len(got) != 4 || got["sonar_portfolio_rating/web"] != 2 {
+		t.Errorf("ratings of failed portfolio are dropped: %v", got)
+	}
+	failing, portfolios = "", portfolios[:1]
+	if got := ratings(); len(got) != 2 || got["sonar_portfolio_rating/all"] != 2 {
+		t.Errorf("ratings %v, want ones of portfolio all only", got)
+	}
+}
+
+// rotate runs cycles of a sliced collection and returns keys of components collected by each cycle in order.
+// Listing of cycles marked slow outlasts cycle deadline, so all components but the first are left for the next cycle
+func rotate(t *testing.T, cfg exporter.Config, cycles int, slow map[int]bool) [][]string {
+	t.Helper()
+	mock := newMock(20)
+	var (
+		mut       sync.Mutex
+		cycle     int
+		collected = make([][]string, cycles)
+	)
+	getComponents := mock.GetComponentsFunc
+	mock.GetComponentsFunc = func() ([]*sonar.ComponentInfo, error) {
+		if slow[cycle] {
+			time.Sleep(cfg.CycleDeadline + 50*time.Millisecond)
+		}
+		return getComponents()
+	}
+	getMeasures := mock.GetMeasuresFunc
+	mock.GetMeasuresFunc = func(key string, metrics []string) (*sonar.Measures, error) {
+		mut.Lock()
+		collected[cycle] = append(collected[cycle], key)
+		mut.Unlock()
+		return getMeasures(key, metrics)
+	}
+
+	collector := exporter.NewCollector(mock, exporter.NewPrometheusExporter(exporter.ExporterConfig{}), cfg)
+	for ; cycle < cycles; cycle++ {
+		if err := collector.RunOnce(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return collected
+}
+
+func TestSlicesCollectComponentsOncePerRotation(t *testing.T) {
+	const slices, rotations = 3, 3
+	cfg := exporter.Config{Concurrency: 1, Slices: slices, CycleDeadline: 200 * time.Millisecond}
+
+	// slice of every component is learned from a rotation without rollovers
+	home := map[string]int{}
+	for i, keys := range rotate(t, cfg, slices, nil) {
+		for _, key := range keys {
+			if prev, found := home[key]; found {
+				t.Fatalf("%s collected by cycles %d and %d of a rotation", key, prev, i)
+			}
+			home[key] = i
+		}
+	}
+	if len(home) != 20 {
+		t.Fatalf("%d components collected by a rotation, want 20", len(home))
+	}
+
+	// deadline is exceeded in the middle of the first rotation and by the last cycle of the second one,
+	// so components left by it are collected by the first cycle of the third rotation
+	collected := rotate(t, cfg, slices*rotations, map[int]bool{1: true, 5: true})
+	for _, slow := range []int{1, 5} {
+		if len(collected[slow]) != 1 {
+			t.Errorf("cycle %d exceeding deadline collected %v, want the first component only", slow, collected[slow])
+		}
+	}
+	// collections of components left by the previous cycle are accounted to it
+	counts := make([]map[string]int, rotations)
+	for i := range counts {
+		counts[i] = map[string]int{}
+	}
+	for i, keys := range collected {
+		rolledOver := true
+		for _, key := range keys {
+			scheduled := i
+			if home[key] != i%slices {
+				scheduled = i - 1
+				if !rolledOver || home[key] != scheduled%slices {
+					t.Errorf("%s of slice %d collected by cycle %d", key, home[key], i)
+				}
+			} else {
+				rolledOver = false
+			}
+			counts[scheduled/slices][key]++
+		}
+	}
+	for r, count := range counts {
+		for key := range home {
+			if count[key] != 1 {
+				t.Errorf("%s collected %d times by rotation %d, want once", key, count[key], r)
+			}
+		}
+	}
+}
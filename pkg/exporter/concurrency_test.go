@@ -0,0 +1,182 @@
+package exporter_test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonartest"
+)
+
+// newMock creates mock of Sonar with n projects. Project p-<i> is tagged team#p-<i> and has i bugs
+func newMock(n int) *sonartest.MockAPI {
+	projects := make([]*sonar.ComponentInfo, n)
+	for i := range projects {
+		key := fmt.Sprintf("p-%02d", i)
+		projects[i] = &sonar.ComponentInfo{Key: key, Name: key, Qualifier: "TRK"}
+	}
+	return &sonartest.MockAPI{
+		GetComponentsFunc: func() ([]*sonar.ComponentInfo, error) {
+			return projects, nil
+		},
+		GetMetricsFunc: func() ([]*sonar.Metric, error) {
+			return sonartest.DefaultMetrics(), nil
+		},
+		GetComponentFunc: func(key string) (*sonar.Component, error) {
+			return component(key, "team#"+key), nil
+		},
+		GetMeasuresFunc: func(key string, _ []string) (*sonar.Measures, error) {
+			return measures(key, "bugs", strings.TrimPrefix(key, "p-")), nil
+		},
+	}
+}
+
+// component creates Sonar component with tags
+func component(key string, tags ...string) *sonar.Component {
+	c := &sonar.Component{Tags: tags}
+	c.Key, c.Name, c.Qualifier = key, key, "TRK"
+	return c
+}
+
+// measures creates measures of the component from pairs of metric keys and values
+func measures(key string, pairs ...string) *sonar.Measures {
+	m := &sonar.Measures{}
+	m.Component.Key = key
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m.Component.Measures = append(m.Component.Measures, &sonar.Measure{Metric: pairs[i], Value: pairs[i+1]})
+	}
+	return m
+}
+
+// labels returns labels of gathered series by name
+func labels(m *dto.Metric) map[string]string {
+	res := map[string]string{}
+	for _, pair := range m.GetLabel() {
+		res[pair.GetName()] = pair.GetValue()
+	}
+	return res
+}
+
+// checkSeries makes sure every series of the family carries labels and value of its own component
+func checkSeries(t *testing.T, families []*dto.MetricFamily) {
+	t.Helper()
+	for _, family := range families {
+		if family.GetName() != "sonar_bugs" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			l := labels(m)
+			key := l["component"]
+			if !strings.HasPrefix(l["team"], key) {
+				t.Errorf("series of %s is labeled with team %q", key, l["team"])
+			}
+			if want, _ := strconv.ParseFloat(strings.TrimPrefix(key, "p-"), 64); m.GetGauge().GetValue() != want {
+				t.Errorf("series of %s has value %v, want %v", key, m.GetGauge().GetValue(), want)
+			}
+		}
+	}
+}
+
+// TestConcurrentCollection runs collection cycles, immediate reports and scrapes at the same time.
+// Meant to be run with -race
+func TestConcurrentCollection(t *testing.T) {
+	const projects, rounds = 40, 20
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{LabelSeparator: "#", MetricTTL: 2})
+	collector := exporter.NewCollector(newMock(projects), exp, exporter.Config{Concurrency: 8})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp, exporter.NewRollup(exp), collector)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if err := collector.RunOnce(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	// reports replace tags of components concurrently with the cycles
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds*projects; i++ {
+				key := fmt.Sprintf("p-%02d", i%projects)
+				exp.Report(component(key, fmt.Sprintf("team#%s-%d", key, w), "misc"),
+					measures(key, "bugs", strings.TrimPrefix(key, "p-")))
+			}
+		}(w)
+	}
+	for s := 0; s < 4; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds*2; i++ {
+				families, err := reg.Gather()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				checkSeries(t, families)
+			}
+		}()
+	}
+	wg.Wait()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, families)
+	for _, family := range families {
+		if family.GetName() == "sonar_bugs" && len(family.GetMetric()) != projects {
+			t.Errorf("%d series of sonar_bugs, want %d", len(family.GetMetric()), projects)
+		}
+	}
+}
+
+// TestConcurrentReportOfSameComponent reports the same component with different tags from many goroutines,
+// so label sets shared between snapshots are never corrupted. Meant to be run with -race
+func TestConcurrentReportOfSameComponent(t *testing.T) {
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{LabelSeparator: "#"})
+	collector := exporter.NewCollector(newMock(1), exp, exporter.Config{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tags := []string{fmt.Sprintf("team#p-00-%d", w), fmt.Sprintf("env#e%d", i%3)}
+				exp.Report(component("p-00", tags...), measures("p-00", "bugs", "00"))
+				if _, err := reg.Gather(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeries(t, families)
+}
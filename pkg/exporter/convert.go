@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// defaultConversionKey is a key of conversion applied to types absent from the table
+const defaultConversionKey = "default"
+
+// Conversion defines how measure values of a metric type are converted to numbers
+type Conversion struct {
+	// Values maps raw measure values to numbers. Values absent from the map are parsed as floats
+	Values map[string]float64 `yaml:"values,omitempty" json:"values,omitempty"`
+	// Skip disables export of metrics of the type
+	Skip bool `yaml:"skip,omitempty" json:"skip,omitempty"`
+}
+
+// Conversions is a conversion table keyed by Sonar metric type, e.g. BOOL or LEVEL.
+// Conversion with 'default' key is applied to types absent from the table
+type Conversions map[string]Conversion
+
+// DefaultConversions returns default conversion table
+func DefaultConversions() Conversions {
+	return Conversions{
+		"BOOL":    {Values: map[string]float64{"true": 1, "false": 0}},
+		levelType: {Values: map[string]float64{"OK": 0, "WARN": 1, "ERROR": 2}},
+		"RATING":  {Values: map[string]float64{"A": 1, "B": 2, "C": 3, "D": 4, "E": 5}},
+		"DATA":    {Skip: true},
+	}
+}
+
+// Merge returns copy of the table with conversions of provided types replaced
+func (c Conversions) Merge(other Conversions) Conversions {
+	merged := make(Conversions, len(c)+len(other))
+	for mType, conv := range c {
+		merged[mType] = conv
+	}
+	for mType, conv := range other {
+		merged[mType] = conv
+	}
+	return merged
+}
+
+// lookup returns conversion of metric type
+func (c Conversions) lookup(mType string) Conversion {
+	if conv, found := c[mType]; found {
+		return conv
+	}
+	return c[defaultConversionKey]
+}
+
+// supported reports whether metrics of the type are exported
+func (c Conversions) supported(mType string) bool {
+	return !c.lookup(mType).Skip
+}
+
+// convert converts measure value of metric type to number
+func (c Conversions) convert(mType string, measure *sonar.Measure) (float64, error) {
+	strVal := measureValue(measure)
+	if val, found := c.lookup(mType).Values[strVal]; found {
+		return val, nil
+	}
+	val, err := strconv.ParseFloat(strVal, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to convert %s value %q: %w", mType, strVal, err)
+	}
+	return val, nil
+}
+
+// states returns known raw values of metric type ordered by their numeric values
+func (c Conversions) states(mType string) []string {
+	values := c.lookup(mType).Values
+	states := make([]string, 0, len(values))
+	for state := range values {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if values[states[i]] == values[states[j]] {
+			return states[i] < states[j]
+		}
+		return values[states[i]] < values[states[j]]
+	})
+	return states
+}
+
+// measureValue returns overall value of the measure or new code value if overall one is absent
+func measureValue(measure *sonar.Measure) string {
+	if measure.Value != "" {
+		return measure.Value
+	}
+	return measure.NewCodeValue()
+}
@@ -0,0 +1,124 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		mType   string
+		value   string
+		want    float64
+		invalid bool
+	}{
+		{mType: "RATING", value: "A", want: 1},
+		{mType: "RATING", value: "B", want: 2},
+		{mType: "RATING", value: "C", want: 3},
+		{mType: "RATING", value: "D", want: 4},
+		{mType: "RATING", value: "E", want: 5},
+		// ratings are reported as numbers by most Sonar versions
+		{mType: "RATING", value: "2.0", want: 2},
+		{mType: "BOOL", value: "true", want: 1},
+		{mType: "BOOL", value: "false", want: 0},
+		{mType: "LEVEL", value: "OK", want: 0},
+		{mType: "LEVEL", value: "WARN", want: 1},
+		{mType: "LEVEL", value: "ERROR", want: 2},
+		{mType: "PERCENT", value: "81.5", want: 81.5},
+		{mType: "INT", value: "42", want: 42},
+		{mType: "RATING", value: "F", invalid: true},
+		{mType: "BOOL", value: "yes", invalid: true},
+		{mType: "LEVEL", value: "NONE", invalid: true},
+		{mType: "INT", value: "", invalid: true},
+	}
+	conversions := DefaultConversions()
+	for _, tt := range tests {
+		got, err := conversions.convert(tt.mType, &sonar.Measure{Metric: "m", Value: tt.value})
+		switch {
+		case tt.invalid && err == nil:
+			t.Errorf("%s %q converted to %v, want error", tt.mType, tt.value, got)
+		case !tt.invalid && err != nil:
+			t.Errorf("%s %q: %v", tt.mType, tt.value, err)
+		case !tt.invalid && got != tt.want:
+			t.Errorf("%s %q converted to %v, want %v", tt.mType, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConvertNewCodeValue(t *testing.T) {
+	measure := &sonar.Measure{Metric: "new_reliability_rating"}
+	measure.Period.Value = "C"
+	if got, err := DefaultConversions().convert("RATING", measure); err != nil || got != 3 {
+		t.Errorf("new code rating converted to %v, error %v, want 3", got, err)
+	}
+}
+
+func TestSupported(t *testing.T) {
+	conversions := DefaultConversions()
+	for mType, want := range map[string]bool{"DATA": false, "RATING": true, "INT": true, "DISTRIB": true} {
+		if got := conversions.supported(mType); got != want {
+			t.Errorf("%s supported is %v, want %v", mType, got, want)
+		}
+	}
+
+	// default key applies to types absent from the table only
+	withDefault := conversions.Merge(Conversions{defaultConversionKey: {Skip: true}})
+	for mType, want := range map[string]bool{"DATA": false, "RATING": true, "INT": false, "DISTRIB": false} {
+		if got := withDefault.supported(mType); got != want {
+			t.Errorf("%s supported with skipping default is %v, want %v", mType, got, want)
+		}
+	}
+}
+
+func TestMergeConfigFileConversions(t *testing.T) {
+	var file struct {
+		Conversions Conversions `yaml:"conversions"`
+	}
+	err := yaml.Unmarshal([]byte(`
+conversions:
+  LEVEL:
+    values: {OK: 1, WARN: 0.5, ERROR: 0}
+  DATA:
+    values: {}
+  default:
+    values: {"N/A": -1}
+`), &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := DefaultConversions()
+	merged := defaults.Merge(file.Conversions)
+
+	tests := []struct {
+		mType string
+		value string
+		want  float64
+	}{
+		{mType: "LEVEL", value: "OK", want: 1},
+		{mType: "LEVEL", value: "WARN", want: 0.5},
+		{mType: "LEVEL", value: "ERROR", want: 0},
+		{mType: "RATING", value: "E", want: 5},
+		{mType: "INT", value: "N/A", want: -1},
+		{mType: "INT", value: "7", want: 7},
+	}
+	for _, tt := range tests {
+		got, err := merged.convert(tt.mType, &sonar.Measure{Metric: "m", Value: tt.value})
+		if err != nil || got != tt.want {
+			t.Errorf("%s %q converted to %v, error %v, want %v", tt.mType, tt.value, got, err, tt.want)
+		}
+	}
+	if !merged.supported("DATA") {
+		t.Error("DATA is skipped though overridden by config file")
+	}
+	if got, want := merged.states("LEVEL"), []string{"ERROR", "WARN", "OK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LEVEL states %v, want %v", got, want)
+	}
+	// defaults are left intact
+	if !reflect.DeepEqual(defaults, DefaultConversions()) {
+		t.Errorf("defaults are modified by merge: %v", defaults)
+	}
+}
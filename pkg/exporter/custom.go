@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// mergeCustomMeasures adds custom (manual) measures of the component to its measures if enabled by configuration.
+// Custom values take precedence over analyzed ones since changed values are pending until the next analysis.
+// Failures are logged only, so analyzed measures of the component are still reported
+func (c *Collector) mergeCustomMeasures(key string, measures *sonar.Measures) {
+	if !c.optionalEnabled(optionalCustomMeasures) {
+		return
+	}
+	custom, err := c.sonar.GetCustomMeasures(key)
+	if err != nil {
+		c.optionalFailed(optionalCustomMeasures, "Unable to collect custom measures of component "+key, err)
+		return
+	}
+
+	registered := map[string]struct{}{}
+	for _, metric := range c.metricKeys() {
+		registered[metric] = struct{}{}
+	}
+	indexes := make(map[string]int, len(measures.Component.Measures))
+	for i, m := range measures.Component.Measures {
+		indexes[m.Metric] = i
+	}
+	for _, cm := range custom {
+		if _, found := registered[cm.Metric.Key]; !found {
+			continue
+		}
+		if i, found := indexes[cm.Metric.Key]; found {
+			measures.Component.Measures[i].Value = cm.Value
+			continue
+		}
+		measures.Component.Measures = append(measures.Component.Measures, &sonar.Measure{Metric: cm.Metric.Key, Value: cm.Value})
+	}
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsConfigPath is a path of endpoint serving effective mapping of Sonar metrics
+const MetricsConfigPath = "/debug/metrics-config"
+
+// metricConfig describes how a Sonar metric is exported
+type metricConfig struct {
+	Key string `json:"key"`
+	// Name is a name of Prometheus metric
+	Name string `json:"name"`
+	// StateSet is a name of state set metric LEVEL metrics are additionally exported as
+	StateSet string `json:"stateSet,omitempty"`
+	// Label is a label distribution metrics are split by
+	Label      string     `json:"label,omitempty"`
+	Type       string     `json:"type"`
+	Conversion Conversion `json:"conversion"`
+	// Exported is true if measures of the metric are collected
+	Exported bool `json:"exported"`
+	// Reported is true if measures of the metric have been reported during last metricTTL cycles
+	Reported bool `json:"reported"`
+}
+
+// metricsConfig returns mapping of Sonar metrics known to the exporter ordered by key
+func (pe *PrometheusExporter) metricsConfig() []metricConfig {
+	pe.mut.RLock()
+	configs := make([]metricConfig, 0, len(pe.definitions))
+	for _, m := range pe.definitions {
+		_, exported := pe.metrics[m.Key]
+		mc := metricConfig{
+			Key:        m.Key,
+			Name:       prometheus.BuildFQName(namespace, "", pe.cleanupName(m.Key)),
+			Label:      distributionLabels[m.Key],
+			Type:       m.Type,
+			Conversion: pe.conversions.lookup(m.Type),
+			Exported:   exported,
+		}
+		if m.Type == levelType {
+			mc.StateSet = mc.Name + "_" + levelLabel
+		}
+		configs = append(configs, mc)
+	}
+	pe.mut.RUnlock()
+
+	pe.familiesMut.Lock()
+	for i := range configs {
+		_, configs[i].Reported = pe.families[configs[i].Key]
+	}
+	pe.familiesMut.Unlock()
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Key < configs[j].Key
+	})
+	return configs
+}
+
+// MetricsConfigHandler serves mapping of Sonar metrics to Prometheus ones as JSON,
+// so filters and conversions can be checked without reading logs
+func (pe *PrometheusExporter) MetricsConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]interface{}{"metrics": pe.metricsConfig()}); err != nil {
+			log.Print(err)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// languagesMetric is a key of metric holding lines of code by language. Its series keep the name of the metric,
+// as series of sonar_ncloc labeled by language would be counted twice by sums of sonar_ncloc
+const languagesMetric = "ncloc_language_distribution"
+
+// distributionLabels maps keys of DATA metrics holding distributions, e.g. 'java=120;js=300',
+// to labels distribution keys are exported with
+var distributionLabels = map[string]string{languagesMetric: "language"}
+
+// addDistribution adds series of distribution measure to the snapshot, one series per distribution key.
+// Returns parsed distribution
+func (pe *PrometheusExporter) addDistribution(snapshot *componentSnapshot, metric *sonar.Metric, measure *sonar.Measure) (map[string]float64, error) {
+	distribution, err := parseDistribution(measureValue(measure))
+	if err != nil {
+		return nil, err
+	}
+
+	set := snapshot.labels.set
+	if measure.Value == "" {
+		set = snapshot.labels.periodSet
+	}
+	label := distributionLabels[metric.Key]
+	for key, val := range distribution {
+		keyNames, keyPairs := labelPairs(withLabels(set, prometheus.Labels{label: key}))
+		snapshot.series = append(snapshot.series, series{
+			desc:   pe.desc(snapshot, metric, "", metric.Description, keyNames),
+			value:  val,
+			labels: keyPairs,
+		})
+	}
+	snapshot.measures++
+	return distribution, nil
+}
+
+// parseDistribution parses distribution value, e.g. 'java=120;js=300'
+func parseDistribution(s string) (map[string]float64, error) {
+	distribution := map[string]float64{}
+	if s == "" {
+		return distribution, nil
+	}
+	for _, entry := range strings.Split(s, ";") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unable to parse distribution entry %q", entry)
+		}
+		val, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse distribution entry %q: %w", entry, err)
+		}
+		distribution[parts[0]] = val
+	}
+	return distribution, nil
+}
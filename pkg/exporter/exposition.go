@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Exposition serves metrics and exports size of the exposition, so the cost of scraping the exporter can be tracked
+type Exposition struct {
+	bytes  *prometheus.GaugeVec
+	series prometheus.Gauge
+}
+
+// NewExposition creates metrics exposition. It must be registered to export its own metrics
+func NewExposition() *Exposition {
+	return &Exposition{
+		bytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "exposition_bytes",
+			Help:      "Size of the previous metrics response body by content encoding",
+		}, []string{"encoding"}),
+		series: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "exposition_series",
+			Help:      "Number of series exposed by the previous metrics response",
+		}),
+	}
+}
+
+// Handler serves metrics of the gatherer. Response is gzipped if scraper accepts it
+func (e *Exposition) Handler(g prometheus.Gatherer) http.Handler {
+	counting := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := g.Gather()
+		series := 0
+		for _, f := range families {
+			series += len(f.GetMetric())
+		}
+		e.series.Set(float64(series))
+		return families, err
+	})
+	h := promhttp.HandlerFor(counting, promhttp.HandlerOpts{DisableCompression: false})
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		cw := &countingWriter{ResponseWriter: w}
+		h.ServeHTTP(cw, rq)
+		encoding := w.Header().Get("Content-Encoding")
+		if encoding == "" {
+			encoding = "identity"
+		}
+		e.bytes.WithLabelValues(encoding).Set(float64(cw.written))
+	})
+}
+
+// Describe implements prometheus.Collector
+func (e *Exposition) Describe(ch chan<- *prometheus.Desc) {
+	e.bytes.Describe(ch)
+	e.series.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (e *Exposition) Collect(ch chan<- prometheus.Metric) {
+	e.bytes.Collect(ch)
+	e.series.Collect(ch)
+}
+
+// countingWriter counts bytes written to the response
+type countingWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += n
+	return n, err
+}
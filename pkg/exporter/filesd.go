@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+// fileSDParam is a URL parameter Prometheus passes project key in when scraping file_sd targets
+const fileSDParam = "__param_" + componentLabel
+
+// fileSDGroup is a target group of Prometheus file based service discovery
+type fileSDGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// writeFileSD writes discovered projects as Prometheus file_sd target groups, one group per project.
+// File is replaced atomically so Prometheus never reads partially written one
+func (c *Collector) writeFileSD() error {
+	c.componentsMut.Lock()
+	groups := make([]fileSDGroup, 0, len(c.components))
+	for key, component := range c.components {
+		labels := c.exporter.tagsToLabels(component.Tags)
+		labels[componentLabel] = key
+		labels[fileSDParam] = key
+		if c.cfg.FileSDScheme != "" {
+			labels[model.SchemeLabel] = c.cfg.FileSDScheme
+		}
+		if c.cfg.FileSDMetricsPath != "" {
+			labels[model.MetricsPathLabel] = c.cfg.FileSDMetricsPath
+		}
+		groups = append(groups, fileSDGroup{Targets: []string{c.cfg.FileSDTarget}, Labels: labels})
+	}
+	c.componentsMut.Unlock()
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Labels[componentLabel] < groups[j].Labels[componentLabel]
+	})
+
+	content, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal targets: %w", err)
+	}
+	return writeFileAtomically(c.cfg.FileSDPath, content)
+}
+
+// writeFileAtomically replaces file with content, so readers never see partially written one
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create file: %w", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to replace file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"errors"
+	"hash/fnv"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// listComponents returns projects of Sonar. Projects are filtered and sorted by Sonar if configured,
+// so sort order decides which projects are collected first, e.g. within request budget
+func (c *Collector) listComponents() ([]*sonar.ComponentInfo, error) {
+	if c.cfg.ProjectFilter == "" && c.cfg.ProjectSort == "" {
+		return c.sonar.GetComponents()
+	}
+	return c.sonar.SearchProjects(c.cfg.ProjectFilter, c.cfg.ProjectSort)
+}
+
+// selectComponents returns components this instance is responsible for
+func (c *Collector) selectComponents(components []*sonar.ComponentInfo) []*sonar.ComponentInfo {
+	if c.cfg.ShardCount <= 1 {
+		return components
+	}
+
+	selected := make([]*sonar.ComponentInfo, 0, len(components)/c.cfg.ShardCount+1)
+	for _, component := range components {
+		if shardOf(component.Key, c.cfg.ShardCount) == c.cfg.ShardIndex {
+			selected = append(selected, component)
+		}
+	}
+	return selected
+}
+
+// shardOf returns shard index of component key
+func shardOf(key string, count int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(count))
+}
+
+// sliceComponents returns components collected during the current cycle when collection is spread across
+// Slices cycles. Slice of a component does not depend on other components, so adding or deleting projects
+// never postpones collection of the rest
+func (c *Collector) sliceComponents(components []*sonar.ComponentInfo) []*sonar.ComponentInfo {
+	if c.cfg.Slices <= 1 {
+		return components
+	}
+
+	current := int(c.tick % uint64(c.cfg.Slices))
+	selected := make([]*sonar.ComponentInfo, 0, len(components)/c.cfg.Slices+1)
+	for _, component := range components {
+		if sliceOf(component.Key, c.cfg.ShardCount, c.cfg.Slices) == current {
+			selected = append(selected, component)
+		}
+	}
+	return selected
+}
+
+// rollOver puts components left uncollected by the previous cycle in front of components of the current cycle.
+// Left components of other slices are collected as well, while deleted ones are forgotten
+func (c *Collector) rollOver(components, sliced []*sonar.ComponentInfo) []*sonar.ComponentInfo {
+	if len(c.pending) == 0 {
+		return sliced
+	}
+	byKey := make(map[string]*sonar.ComponentInfo, len(components))
+	for _, component := range components {
+		byKey[component.Key] = component
+	}
+	ordered := make([]*sonar.ComponentInfo, 0, len(sliced)+len(c.pending))
+	pending := make(map[string]struct{}, len(c.pending))
+	for _, key := range c.pending {
+		if component, found := byKey[key]; found {
+			ordered = append(ordered, component)
+			pending[key] = struct{}{}
+		}
+	}
+	for _, component := range sliced {
+		if _, found := pending[component.Key]; !found {
+			ordered = append(ordered, component)
+		}
+	}
+	c.pending = nil
+	return ordered
+}
+
+// sliceOf returns slice index of component key. Hash bits used for sharding are skipped,
+// so components of a shard are spread across all slices
+func sliceOf(key string, shards, count int) int {
+	if shards < 1 {
+		shards = 1
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() / uint32(shards) % uint32(count))
+}
+
+// errOptedOut is returned for components tagged with opt-out tag
+var errOptedOut = errors.New("component opted out of export")
+
+// optedOut reports whether component is tagged with opt-out tag
+func (c *Collector) optedOut(component *sonar.Component) bool {
+	return c.cfg.OptOutTag != "" && hasTag(component, c.cfg.OptOutTag)
+}
+
+// hasTag reports whether component is tagged with the tag
+func hasTag(component *sonar.Component, tag string) bool {
+	for _, t := range component.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"strconv"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+var (
+	// gateThresholdMetric is a pseudo metric of error thresholds of quality gate conditions
+	gateThresholdMetric = &sonar.Metric{
+		Key:         "quality_gate_threshold",
+		Description: "Error threshold of quality gate condition on the metric. Comparator is GT or LT",
+	}
+	// gateValueMetric is a pseudo metric of values quality gate conditions are evaluated on
+	gateValueMetric = &sonar.Metric{
+		Key:         "quality_gate_condition_value",
+		Description: "Value of the metric quality gate condition is evaluated on",
+	}
+	// gateInfoMetric is a pseudo metric of quality gates assigned to projects
+	gateInfoMetric = &sonar.Metric{
+		Key:         "project_quality_gate_info",
+		Description: "Quality gate assigned to the project. is_default is set if project uses the default gate",
+	}
+)
+
+// collectGateConditions collects thresholds and actual values of component's quality gate conditions
+// if enabled by configuration. Failures are logged only, so measures of the component are still reported
+func (c *Collector) collectGateConditions(key string) []extraSeries {
+	if !c.optionalEnabled(optionalGateConditions) {
+		return nil
+	}
+	status, err := c.sonar.GetProjectStatus(key)
+	if err != nil {
+		c.optionalFailed(optionalGateConditions, "Unable to collect quality gate conditions of component "+key, err)
+		return nil
+	}
+	extras := make([]extraSeries, 0, 2*len(status.Conditions))
+	for _, condition := range status.Conditions {
+		if threshold, err := strconv.ParseFloat(condition.ErrorThreshold, 64); err == nil {
+			extras = append(extras, extraSeries{
+				metric: gateThresholdMetric,
+				labels: map[string]string{"metric": condition.MetricKey, "comparator": condition.Comparator},
+				value:  threshold,
+			})
+		}
+		if actual, err := strconv.ParseFloat(condition.ActualValue, 64); err == nil {
+			extras = append(extras, extraSeries{
+				metric: gateValueMetric,
+				labels: map[string]string{"metric": condition.MetricKey},
+				value:  actual,
+			})
+		}
+	}
+	return extras
+}
+
+// collectGateInfo collects quality gate assigned to the component if enabled by configuration
+func (c *Collector) collectGateInfo(key string) []extraSeries {
+	if !c.optionalEnabled(optionalGateInfo) {
+		return nil
+	}
+	gate, err := c.sonar.GetProjectQualityGate(key)
+	if err != nil {
+		c.optionalFailed(optionalGateInfo, "Unable to collect quality gate of component "+key, err)
+		return nil
+	}
+	return []extraSeries{{
+		metric: gateInfoMetric,
+		labels: map[string]string{"gate": gate.Name, "is_default": strconv.FormatBool(gate.Default)},
+		value:  1,
+	}}
+}
@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HandlerConfig configures exporter embedded into another Go service
+type HandlerConfig struct {
+	// Sonar is a Sonar API measures are collected from, e.g. *sonar.Client
+	Sonar SonarAPI
+	// Exporter configures conversion of measures to Prometheus metrics
+	Exporter ExporterConfig
+	// Collector configures collection. ScrapeTimeout is required, it is an interval between collection cycles
+	Collector Config
+	// Done stops background collection once closed. Nil keeps collecting for the lifetime of the process
+	Done <-chan struct{}
+}
+
+// NewHandler creates handler serving metrics of an exporter embedded into another Go service, so the service
+// may mount it instead of running the exporter separately. Metric definitions are loaded right away and
+// collection runs in background until Done is closed. Metrics are kept in a registry of their own,
+// so they never clash with metrics of the service
+func NewHandler(cfg HandlerConfig) (http.Handler, error) {
+	if cfg.Sonar == nil {
+		return nil, errors.New("sonar API is required")
+	}
+	if cfg.Collector.ScrapeTimeout <= 0 {
+		return nil, errors.New("scrape timeout must be positive")
+	}
+
+	exp := NewPrometheusExporter(cfg.Exporter)
+	collector := NewCollector(cfg.Sonar, exp, cfg.Collector)
+	exposition := NewExposition()
+	reg := prometheus.NewRegistry()
+	for _, c := range []prometheus.Collector{exp, NewRollup(exp), collector, exposition} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := collector.init(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := schedule(cfg.Done, cfg.Collector.InitialDelay, collector.cycle); err != nil {
+			log.Printf("Collection is stopped: %v", err)
+		}
+	}()
+	return promhttp.InstrumentMetricHandler(reg, exposition.Handler(reg)), nil
+}
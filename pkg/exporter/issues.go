@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+var (
+	// issueAgeMetric is a pseudo metric of open issues by age
+	issueAgeMetric = &sonar.Metric{
+		Key:         "open_issues_age_days",
+		Description: "Number of open issues created less than 'le' days ago",
+	}
+	// resolvedIssuesMetric is a pseudo metric of issues dismissed by users
+	resolvedIssuesMetric = &sonar.Metric{
+		Key:         "resolved_issues",
+		Description: "Number of issues resolved as false positive or accepted (won't fix) by severity",
+	}
+	// ruleIssuesMetric is a pseudo metric of open issues by rule
+	ruleIssuesMetric = &sonar.Metric{
+		Key:         "rule_issues",
+		Description: "Number of open issues of the rules having the most of them",
+	}
+)
+
+// dismissedResolutions are resolutions of issues dismissed by users rather than fixed
+var dismissedResolutions = []string{"FALSE-POSITIVE", "WONTFIX"}
+
+// collectIssues collects component's issue statistics enabled by configuration.
+// Failures are logged only, so measures of the component are still reported
+func (c *Collector) collectIssues(key string) []extraSeries {
+	var extras []extraSeries
+	if c.optionalEnabled(optionalIssueAges) {
+		ages, err := c.issueAges(key, time.Now())
+		if err != nil {
+			c.optionalFailed(optionalIssueAges, "Unable to collect issue ages of component "+key, err)
+		}
+		extras = append(extras, ages...)
+	}
+	if c.optionalEnabled(optionalResolvedIssues) {
+		resolved, err := c.resolvedIssues(key)
+		if err != nil {
+			c.optionalFailed(optionalResolvedIssues, "Unable to collect resolved issues of component "+key, err)
+		}
+		extras = append(extras, resolved...)
+	}
+	if c.optionalEnabled(optionalTopRules) {
+		rules, err := c.topRules(key)
+		if err != nil {
+			c.optionalFailed(optionalTopRules, "Unable to collect top rules of component "+key, err)
+		}
+		extras = append(extras, rules...)
+	}
+	return extras
+}
+
+// topRules counts open issues of TopRules rules having the most of them
+func (c *Collector) topRules(key string) ([]extraSeries, error) {
+	issues, err := c.sonar.SearchIssues(url.Values{
+		"componentKeys": {key},
+		"resolved":      {"false"},
+		"facets":        {"rules"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// facet values are ordered by count already
+	values := issues.Facet("rules")
+	if len(values) > c.cfg.TopRules {
+		values = values[:c.cfg.TopRules]
+	}
+	rules := make([]extraSeries, 0, len(values))
+	for _, rule := range values {
+		rules = append(rules, extraSeries{
+			metric: ruleIssuesMetric,
+			labels: map[string]string{"rule": rule.Val},
+			value:  float64(rule.Count),
+		})
+	}
+	return rules, nil
+}
+
+// resolvedIssues counts issues dismissed as false positive or accepted by severity
+func (c *Collector) resolvedIssues(key string) ([]extraSeries, error) {
+	var resolved []extraSeries
+	for _, resolution := range dismissedResolutions {
+		issues, err := c.sonar.SearchIssues(url.Values{
+			"componentKeys": {key},
+			"resolutions":   {resolution},
+			"facets":        {"severities"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, severity := range issues.Facet("severities") {
+			resolved = append(resolved, extraSeries{
+				metric: resolvedIssuesMetric,
+				labels: map[string]string{"resolution": resolution, "severity": severity.Val},
+				value:  float64(severity.Count),
+			})
+		}
+	}
+	return resolved, nil
+}
+
+// issueAges counts open issues created within every age bucket, cumulatively like histogram buckets
+func (c *Collector) issueAges(key string, now time.Time) ([]extraSeries, error) {
+	query := url.Values{"componentKeys": {key}, "resolved": {"false"}}
+	total, err := c.sonar.SearchIssues(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ages := make([]extraSeries, 0, len(c.cfg.IssueAgeBuckets)+1)
+	for _, days := range c.cfg.IssueAgeBuckets {
+		query.Set("createdAfter", sonar.Date(now.AddDate(0, 0, -days)).String())
+		issues, err := c.sonar.SearchIssues(query)
+		if err != nil {
+			return nil, err
+		}
+		ages = append(ages, extraSeries{
+			metric: issueAgeMetric,
+			labels: map[string]string{"le": strconv.Itoa(days)},
+			value:  float64(issues.Count()),
+		})
+	}
+	return append(ages, extraSeries{
+		metric: issueAgeMetric,
+		labels: map[string]string{"le": "+Inf"},
+		value:  float64(total.Count()),
+	}), nil
+}
@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// reservedLabels are labels the exporter adds to series itself, so tags can not be converted to them
+var reservedLabels = map[string]struct{}{
+	componentLabel:       {},
+	levelLabel:           {},
+	periodModeLabel:      {},
+	periodParameterLabel: {},
+	periodIndexLabel:     {},
+}
+
+func init() {
+	for _, label := range distributionLabels {
+		reservedLabels[label] = struct{}{}
+	}
+}
+
+// ValidateLabelName makes sure name is a valid name of a label converted from tags
+func ValidateLabelName(name string) error {
+	if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	if _, reserved := reservedLabels[name]; reserved {
+		return fmt.Errorf("label name %q is reserved by the exporter", name)
+	}
+	return nil
+}
+
+// MissingLabelAction defines what happens to components lacking a label or having it blank
+type MissingLabelAction string
+
+const (
+	// MissingLabelOmit exports series of the component without the label
+	MissingLabelOmit MissingLabelAction = "omit"
+	// MissingLabelDrop does not export series of the component
+	MissingLabelDrop MissingLabelAction = "drop"
+	// MissingLabelDefault exports series of the component with default label value
+	MissingLabelDefault MissingLabelAction = "default"
+)
+
+// LabelPolicy defines handling of a label converted from tags
+type LabelPolicy struct {
+	// Missing is an action applied to components lacking the label. Empty means omit
+	Missing MissingLabelAction `yaml:"missing,omitempty" json:"missing,omitempty"`
+	// Default is a value of the label for 'default' action
+	Default string `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// LabelPolicies are label policies keyed by label name
+type LabelPolicies map[string]LabelPolicy
+
+// Validate makes sure actions are known and default values are provided where required
+func (p LabelPolicies) Validate() error {
+	for label, policy := range p {
+		if err := ValidateLabelName(label); err != nil {
+			return err
+		}
+		switch policy.Missing {
+		case "", MissingLabelOmit, MissingLabelDrop:
+		case MissingLabelDefault:
+			if policy.Default == "" {
+				return fmt.Errorf("default value of label %s is required", label)
+			}
+		default:
+			return fmt.Errorf("unknown missing label action of label %s: %s", label, policy.Missing)
+		}
+	}
+	return nil
+}
+
+// apply applies policies to labels in place. Returns sorted names of missing labels
+// and false if component must not be exported
+func (p LabelPolicies) apply(labels prometheus.Labels) ([]string, bool) {
+	var missing []string
+	keep := true
+	for label, policy := range p {
+		if labels[label] != "" {
+			continue
+		}
+		missing = append(missing, label)
+		switch policy.Missing {
+		case MissingLabelDrop:
+			keep = false
+		case MissingLabelDefault:
+			labels[label] = policy.Default
+		default:
+			delete(labels, label)
+		}
+	}
+	sort.Strings(missing)
+	return missing, keep
+}
+
+// withLabels returns a copy of the label set extended with labels. Labels of the same names are replaced,
+// so the set itself is never mutated and may be shared between snapshots
+func withLabels(set, labels prometheus.Labels) prometheus.Labels {
+	merged := make(prometheus.Labels, len(set)+len(labels))
+	for name, value := range set {
+		merged[name] = value
+	}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	return merged
+}
+
+// equalLabels reports whether label sets have the same labels regardless of their order
+func equalLabels(a, b prometheus.Labels) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, found := b[name]; !found || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// labelPairs converts label set to names and label pairs ordered by name as descriptors expect them.
+// Invalid UTF-8 sequences are replaced since they would fail the whole scrape
+func labelPairs(set prometheus.Labels) (names []string, pairs []*dto.LabelPair) {
+	names = make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs = make([]*dto.LabelPair, len(names))
+	for i := range names {
+		name, value := names[i], strings.ToValidUTF8(set[names[i]], "\uFFFD")
+		pairs[i] = &dto.LabelPair{Name: &name, Value: &value}
+	}
+	return names, pairs
+}
@@ -0,0 +1,185 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestApplyLabelPolicies(t *testing.T) {
+	policies := LabelPolicies{
+		"team":  {Missing: MissingLabelDefault, Default: "unowned"},
+		"env":   {Missing: MissingLabelDrop},
+		"owner": {Missing: MissingLabelOmit},
+		"tier":  {},
+	}
+	tests := []struct {
+		name    string
+		labels  prometheus.Labels
+		want    prometheus.Labels
+		missing []string
+		keep    bool
+	}{
+		{
+			name:   "all present",
+			labels: prometheus.Labels{"team": "web", "env": "prod", "owner": "jane", "tier": "1"},
+			want:   prometheus.Labels{"team": "web", "env": "prod", "owner": "jane", "tier": "1"},
+			keep:   true,
+		},
+		{
+			name:    "default",
+			labels:  prometheus.Labels{"env": "prod", "owner": "jane", "tier": "1"},
+			want:    prometheus.Labels{"team": "unowned", "env": "prod", "owner": "jane", "tier": "1"},
+			missing: []string{"team"},
+			keep:    true,
+		},
+		{
+			name:    "omit blank",
+			labels:  prometheus.Labels{"team": "web", "env": "prod", "owner": "", "tier": ""},
+			want:    prometheus.Labels{"team": "web", "env": "prod"},
+			missing: []string{"owner", "tier"},
+			keep:    true,
+		},
+		{
+			name:    "drop",
+			labels:  prometheus.Labels{"team": "web", "owner": "jane", "tier": "1"},
+			want:    prometheus.Labels{"team": "web", "owner": "jane", "tier": "1"},
+			missing: []string{"env"},
+		},
+		{
+			name:    "all missing",
+			labels:  prometheus.Labels{},
+			want:    prometheus.Labels{"team": "unowned"},
+			missing: []string{"env", "owner", "team", "tier"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, keep := policies.apply(tt.labels)
+			if !reflect.DeepEqual(missing, tt.missing) || keep != tt.keep {
+				t.Errorf("missing %v, keep %v, want missing %v, keep %v", missing, keep, tt.missing, tt.keep)
+			}
+			if !reflect.DeepEqual(tt.labels, tt.want) {
+				t.Errorf("labels %v, want %v", tt.labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelPoliciesOfExportedSeries(t *testing.T) {
+	pe := NewPrometheusExporter(ExporterConfig{LabelSeparator: "#", LabelPolicies: LabelPolicies{
+		"team": {Missing: MissingLabelDefault, Default: "unowned"},
+		"env":  {Missing: MissingLabelDrop},
+		"tier": {Missing: MissingLabelOmit},
+	}})
+	pe.registerMetrics([]*sonar.Metric{{Key: "bugs", Type: "INT"}})
+	for key, tags := range map[string][]string{
+		"complete":  {"team#web", "env#prod", "tier#1"},
+		"unowned":   {"env#prod", "tier#1"},
+		"untiered":  {"team#web", "env#prod"},
+		"unstaged":  {"team#web", "tier#1"},
+		"untracked": nil,
+	} {
+		c := &sonar.Component{Tags: tags}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		m.Component.Measures = []*sonar.Measure{{Metric: "bugs", Value: "1"}}
+		pe.Report(c, m)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(pe)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]prometheus.Labels{}
+	for _, family := range families {
+		if family.GetName() != "sonar_bugs" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := prometheus.Labels{}
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			got[labels[componentLabel]] = labels
+			delete(labels, componentLabel)
+		}
+	}
+	want := map[string]prometheus.Labels{
+		"complete": {"team": "web", "env": "prod", "tier": "1"},
+		"unowned":  {"team": "unowned", "env": "prod", "tier": "1"},
+		"untiered": {"team": "web", "env": "prod"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labels of exported series %v, want %v", got, want)
+	}
+}
+
+func TestLabelMismatches(t *testing.T) {
+	pe := NewPrometheusExporter(ExporterConfig{LabelSeparator: "#", LabelPolicies: LabelPolicies{
+		"team": {Missing: MissingLabelDrop},
+	}})
+	pe.registerMetrics([]*sonar.Metric{{Key: "bugs", Type: "INT"}})
+	report := func(b *batch, key string, tags ...string) {
+		c := &sonar.Component{Tags: tags}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		m.Component.Measures = []*sonar.Measure{{Metric: "bugs", Value: "1"}}
+		b.report(c, m, nil)
+	}
+	mismatches := func() map[string]float64 {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(pe)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != "sonar_exporter_label_mismatch_total" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				res[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+			}
+		}
+		return res
+	}
+	all := map[string]struct{}{"owned": {}, "orphan": {}, "stray": {}}
+
+	for i := 0; i < 2; i++ {
+		b := pe.newBatch()
+		report(b, "owned", "team#web")
+		report(b, "orphan")
+		report(b, "stray", "team#")
+		pe.commit(b, all)
+	}
+	if got, want := mismatches(), map[string]float64{"orphan": 2, "stray": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatches %v, want %v", got, want)
+	}
+
+	// orphan is removed from Sonar, stray is reported though filtered out
+	b := pe.newBatch()
+	report(b, "owned", "team#web")
+	report(b, "stray")
+	pe.commit(b, map[string]struct{}{"owned": {}})
+	if got := mismatches(); len(got) != 0 {
+		t.Errorf("mismatches %v of removed components, want none", got)
+	}
+
+	// orphan is refreshed on demand before it is removed
+	c := &sonar.Component{}
+	c.Key, c.Name, c.Qualifier = "orphan", "orphan", "TRK"
+	pe.Report(c, &sonar.Measures{})
+	pe.retain(map[string]struct{}{"owned": {}})
+	if got := mismatches(); len(got) != 0 {
+		t.Errorf("mismatches %v of retired components, want none", got)
+	}
+}
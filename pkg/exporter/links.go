@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// projectLinksMetric is a pseudo metric of project links, e.g. homepage, CI and SCM
+var projectLinksMetric = &sonar.Metric{
+	Key:         "project_links",
+	Description: "Links of the project by type. Always 1",
+}
+
+// collectLinks collects component's links if enabled by configuration.
+// Failures are logged only, so measures of the component are still reported
+func (c *Collector) collectLinks(key string) []extraSeries {
+	if !c.optionalEnabled(optionalLinks) {
+		return nil
+	}
+	links, err := c.sonar.GetProjectLinks(key)
+	if err != nil {
+		c.optionalFailed(optionalLinks, "Unable to collect links of component "+key, err)
+		return nil
+	}
+	extras := make([]extraSeries, 0, len(links))
+	for _, link := range links {
+		labels := map[string]string{"type": link.Type, "url": link.URL}
+		// custom links may share type, so they are told apart by name
+		if link.Name != "" {
+			labels["name"] = link.Name
+		}
+		extras = append(extras, extraSeries{metric: projectLinksMetric, labels: labels, value: 1})
+	}
+	return extras
+}
@@ -0,0 +1,45 @@
+package exporter
+
+import (
+	"log"
+	"time"
+)
+
+// maintenanceStatuses are statuses of Sonar server during upgrades and restarts, see api/system/status
+var maintenanceStatuses = map[string]struct{}{
+	"STARTING":             {},
+	"RESTARTING":           {},
+	"DB_MIGRATION_NEEDED":  {},
+	"DB_MIGRATION_RUNNING": {},
+}
+
+// underMaintenance checks status of Sonar server and reports whether it is under maintenance.
+// Status which can not be obtained is not a maintenance, so failures are handled as usual
+func (c *Collector) underMaintenance() bool {
+	status, err := c.sonar.GetSystemStatus()
+	maintenance := false
+	if err == nil {
+		_, maintenance = maintenanceStatuses[status.Status]
+	}
+	switch {
+	case maintenance && c.maintenance == 0:
+		log.Printf("Sonar is under maintenance (%s), collection is paused", status.Status)
+		c.self.serverMaintenance.Set(1)
+	case !maintenance && c.maintenance > 0:
+		log.Printf("Sonar maintenance is over after %d skipped cycles, collection is resumed", c.maintenance)
+		c.self.serverMaintenance.Set(0)
+		c.maintenance = 0
+	}
+	return maintenance
+}
+
+// maintenanceDelay accounts a cycle skipped due to maintenance and returns delay before the next check,
+// which increases the same way as backoff of failed cycles
+func (c *Collector) maintenanceDelay() time.Duration {
+	c.maintenance++
+	shift := c.maintenance - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	return c.interval << shift
+}
@@ -0,0 +1,229 @@
+package exporter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// mergeWeightMetric is a key of metric percentages of merged components are weighted by
+const mergeWeightMetric = "ncloc"
+
+// levelSeverity orders quality gate levels from the best to the worst
+var levelSeverity = map[string]int{"OK": 0, "WARN": 1, "ERROR": 2}
+
+// Merges are keys of projects merged into a single logical component by its key, e.g. a monorepo split
+// into several Sonar projects
+type Merges map[string][]string
+
+// Validate makes sure every logical component has projects and every project is merged into a single one
+func (m Merges) Validate() error {
+	merged := map[string]string{}
+	for key, projects := range m {
+		if len(projects) == 0 {
+			return fmt.Errorf("projects of merged component %s are required", key)
+		}
+		for _, project := range projects {
+			if other, found := merged[project]; found {
+				return fmt.Errorf("project %s is merged into both %s and %s", project, other, key)
+			}
+			merged[project] = key
+		}
+	}
+	for key := range m {
+		if project, found := merged[key]; found {
+			return fmt.Errorf("merged component %s is a project of merged component %s", key, project)
+		}
+	}
+	return nil
+}
+
+// mergedMember is the last collected component merged into a logical one along with its measures
+type mergedMember struct {
+	component *sonar.Component
+	measures  *sonar.Measures
+}
+
+// keepMerged keeps measures of the component if it is merged into a logical one
+func (c *Collector) keepMerged(component *sonar.Component, measures *sonar.Measures) {
+	if _, found := c.mergedInto[component.Key]; !found {
+		return
+	}
+	c.mergedMut.Lock()
+	defer c.mergedMut.Unlock()
+	c.merged[component.Key] = &mergedMember{component: component, measures: measures}
+}
+
+// reportMerged reports logical components merged from measures of their projects kept so far and adds their keys.
+// Projects absent from keys are forgotten. Logical component clashing with a project is skipped
+func (c *Collector) reportMerged(b *batch, keys map[string]struct{}) {
+	if len(c.cfg.Merges) == 0 {
+		return
+	}
+	c.mergedMut.Lock()
+	defer c.mergedMut.Unlock()
+	for key := range c.merged {
+		if _, found := keys[key]; !found {
+			delete(c.merged, key)
+		}
+	}
+
+	for key, projects := range c.cfg.Merges {
+		if _, found := keys[key]; found {
+			log.Printf("Merged component %s clashes with a project of the same key, it is not exported", key)
+			continue
+		}
+		members := make([]*mergedMember, 0, len(projects))
+		for _, project := range projects {
+			if member, found := c.merged[project]; found {
+				members = append(members, member)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		b.reportMerged(mergeComponents(key, members), c.exporter.mergeMeasures(key, members))
+		keys[key] = struct{}{}
+	}
+}
+
+// reportMerged reports logical component merged from projects. Such components are left out of rollups,
+// so projects are not counted twice
+func (b *batch) reportMerged(component *sonar.Component, measures *sonar.Measures) {
+	snapshot := b.pe.snapshot(component, measures, nil)
+	snapshot.merged = true
+
+	b.mut.Lock()
+	b.components[component.Key] = snapshot
+	b.mut.Unlock()
+}
+
+// mergeComponents builds logical component of merged ones. Tags are united and the latest analysis is taken
+func mergeComponents(key string, members []*mergedMember) *sonar.Component {
+	merged := &sonar.Component{}
+	merged.Key, merged.Name, merged.Qualifier = key, key, members[0].component.Qualifier
+	tags := map[string]struct{}{}
+	for _, member := range members {
+		for _, tag := range member.component.Tags {
+			tags[tag] = struct{}{}
+		}
+		if member.component.AnalysisDate.Time().After(merged.AnalysisDate.Time()) {
+			merged.AnalysisDate = member.component.AnalysisDate
+		}
+	}
+	for tag := range tags {
+		merged.Tags = append(merged.Tags, tag)
+	}
+	sort.Strings(merged.Tags)
+	return merged
+}
+
+// mergeMeasures merges measures of the members by metric type: counts and durations are summed, percentages are
+// averaged weighted by lines of code, ratings and quality gate levels take the worst value.
+// Measures of other types are not merged
+func (pe *PrometheusExporter) mergeMeasures(key string, members []*mergedMember) *sonar.Measures {
+	merged := &sonar.Measures{Period: members[0].measures.NewCodePeriod()}
+	merged.Component.Key, merged.Component.Name = key, key
+
+	weights := make([]float64, len(members))
+	var total float64
+	byMetric := map[string][]*sonar.Measure{}
+	var metrics []string
+	for i, member := range members {
+		for _, measure := range member.measures.Component.Measures {
+			if measure.Metric == mergeWeightMetric {
+				weights[i], _ = strconv.ParseFloat(measure.Value, 64)
+				total += weights[i]
+			}
+			if _, found := byMetric[measure.Metric]; !found {
+				metrics = append(metrics, measure.Metric)
+				byMetric[measure.Metric] = make([]*sonar.Measure, len(members))
+			}
+			byMetric[measure.Metric][i] = measure
+		}
+		merged.Metrics = append(merged.Metrics, member.measures.Metrics...)
+	}
+	// members are weighted equally unless lines of code are known
+	if total == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	for _, metric := range metrics {
+		mType := pe.metricType(metric)
+		values := byMetric[metric]
+		measure := &sonar.Measure{Metric: metric}
+		measure.Value = mergeValues(mType, values, weights, func(m *sonar.Measure) string { return m.Value })
+		measure.Period.Value = mergeValues(mType, values, weights, (*sonar.Measure).NewCodeValue)
+		if measure.Value != "" || measure.Period.Value != "" {
+			merged.Component.Measures = append(merged.Component.Measures, measure)
+		}
+	}
+	return merged
+}
+
+// mergeValues merges values of the metric type. Empty if no member has a value or the type is not merged
+func mergeValues(mType string, measures []*sonar.Measure, weights []float64, value func(*sonar.Measure) string) string {
+	var sum, weight, worst float64
+	var level string
+	found := false
+	for i, measure := range measures {
+		if measure == nil || value(measure) == "" {
+			continue
+		}
+		if mType == levelType {
+			if v := value(measure); !found || levelSeverity[v] > levelSeverity[level] {
+				level = v
+			}
+			found = true
+			continue
+		}
+		v, err := strconv.ParseFloat(value(measure), 64)
+		if err != nil {
+			continue
+		}
+		switch mType {
+		case "INT", "FLOAT", "WORK_DUR", "MILLISEC":
+			sum += v
+		case "PERCENT":
+			sum += v * weights[i]
+			weight += weights[i]
+		case "RATING":
+			if !found || v > worst {
+				worst = v
+			}
+		default:
+			return ""
+		}
+		found = true
+	}
+
+	switch {
+	case !found:
+		return ""
+	case mType == levelType:
+		return level
+	case mType == "RATING":
+		return strconv.FormatFloat(worst, 'f', -1, 64)
+	case mType == "PERCENT":
+		if weight == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(sum/weight, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(sum, 'f', -1, 64)
+}
+
+// metricType returns type of the exported metric. Empty if the metric is not exported
+func (pe *PrometheusExporter) metricType(key string) string {
+	pe.mut.RLock()
+	defer pe.mut.RUnlock()
+	if m, found := pe.metrics[key]; found {
+		return m.Type
+	}
+	return ""
+}
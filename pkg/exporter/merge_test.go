@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// values creates measures of a metric from values, nil where value is empty
+func values(vals ...string) []*sonar.Measure {
+	res := make([]*sonar.Measure, len(vals))
+	for i, v := range vals {
+		if v != "" {
+			res[i] = &sonar.Measure{Metric: "m", Value: v}
+		}
+	}
+	return res
+}
+
+func TestMergeValues(t *testing.T) {
+	value := func(m *sonar.Measure) string { return m.Value }
+	tests := []struct {
+		name     string
+		mType    string
+		measures []*sonar.Measure
+		weights  []float64
+		want     string
+	}{
+		{name: "sum", mType: "INT", measures: values("3", "4"), weights: []float64{1, 1}, want: "7"},
+		{name: "sum of durations", mType: "WORK_DUR", measures: values("30", "", "15"), weights: []float64{1, 1, 1}, want: "45"},
+		{name: "weighted by ncloc", mType: "PERCENT", measures: values("50", "80"), weights: []float64{100, 300}, want: "72.5"},
+		{name: "equal weights", mType: "PERCENT", measures: values("50", "80"), weights: []float64{1, 1}, want: "65"},
+		{name: "zero weight member", mType: "PERCENT", measures: values("50", "80"), weights: []float64{0, 300}, want: "80"},
+		{name: "zero weights only", mType: "PERCENT", measures: values("50", "80"), weights: []float64{0, 0}},
+		{name: "missing percentage", mType: "PERCENT", measures: values("", "80"), weights: []float64{100, 300}, want: "80"},
+		{name: "worst rating", mType: "RATING", measures: values("1.0", "4.0", "2.0"), weights: []float64{1, 1, 1}, want: "4"},
+		{name: "missing rating", mType: "RATING", measures: values("", "2.0"), weights: []float64{1, 1}, want: "2"},
+		{name: "worst level", mType: "LEVEL", measures: values("OK", "ERROR", "WARN"), weights: []float64{1, 1, 1}, want: "ERROR"},
+		{name: "missing level", mType: "LEVEL", measures: values("", "WARN"), weights: []float64{1, 1}, want: "WARN"},
+		{name: "missing everywhere", mType: "INT", measures: values("", ""), weights: []float64{1, 1}},
+		{name: "unparsable skipped", mType: "INT", measures: values("x", "2"), weights: []float64{1, 1}, want: "2"},
+		{name: "not merged type", mType: "DATA", measures: values("a", "b"), weights: []float64{1, 1}},
+	}
+	for _, tt := range tests {
+		if got := mergeValues(tt.mType, tt.measures, tt.weights, value); got != tt.want {
+			t.Errorf("%s: merged %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeMeasures(t *testing.T) {
+	pe := benchExporter()
+	member := func(key string, pairs ...string) *mergedMember {
+		c := &sonar.Component{}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		for i := 0; i+1 < len(pairs); i += 2 {
+			m.Component.Measures = append(m.Component.Measures, &sonar.Measure{Metric: pairs[i], Value: pairs[i+1]})
+		}
+		return &mergedMember{component: c, measures: m}
+	}
+	tests := []struct {
+		name    string
+		members []*mergedMember
+		want    map[string]string
+	}{
+		{
+			name: "weighted by ncloc",
+			members: []*mergedMember{
+				member("api", "ncloc", "1000", "coverage", "90", "bugs", "2", "sqale_rating", "1.0", "alert_status", "OK"),
+				member("web", "ncloc", "3000", "coverage", "50", "bugs", "5", "sqale_rating", "3.0", "alert_status", "ERROR"),
+			},
+			want: map[string]string{"ncloc": "4000", "coverage": "60", "bugs": "7", "sqale_rating": "3", "alert_status": "ERROR"},
+		},
+		{
+			name: "equal weights without ncloc",
+			members: []*mergedMember{
+				member("api", "coverage", "90"),
+				member("web", "coverage", "50"),
+			},
+			want: map[string]string{"coverage": "70"},
+		},
+		{
+			name: "member missing metrics",
+			members: []*mergedMember{
+				member("api", "ncloc", "1000", "coverage", "90", "bugs", "2"),
+				member("docs", "ncloc", "3000"),
+			},
+			want: map[string]string{"ncloc": "4000", "coverage": "90", "bugs": "2"},
+		},
+	}
+	for _, tt := range tests {
+		merged := pe.mergeMeasures("shop", tt.members)
+		got := map[string]string{}
+		for _, m := range merged.Component.Measures {
+			got[m.Metric] = m.Value
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: merged %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for metric, want := range tt.want {
+			if got[metric] != want {
+				t.Errorf("%s: merged %s is %q, want %q", tt.name, metric, got[metric], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const selfSubsystem = "exporter"
+
+// selfMetrics describe exporter's own state
+type selfMetrics struct {
+	cycleDuration prometheus.Gauge
+	cycleProjects *prometheus.GaugeVec
+	cycleAPICalls prometheus.Gauge
+	cycleMeasures prometheus.Gauge
+	interval      prometheus.Gauge
+	successRatio  prometheus.Gauge
+
+	budgetExhausted  prometheus.Counter
+	deadlineExceeded prometheus.Counter
+
+	inFlight          prometheus.Gauge
+	queued            prometheus.Gauge
+	workerUtilization prometheus.Gauge
+
+	consecutiveFailures prometheus.Gauge
+	leader              prometheus.Gauge
+	quietHours          prometheus.Gauge
+	tokenExpiresIn      *prometheus.GaugeVec
+	serverInfo          *prometheus.GaugeVec
+	serverMaintenance   prometheus.Gauge
+	sonarURL            *prometheus.GaugeVec
+
+	portfolioRating      *prometheus.GaugeVec
+	portfolioWorstRating *prometheus.GaugeVec
+
+	collectorEnabled *prometheus.GaugeVec
+}
+
+func newSelfMetrics() *selfMetrics {
+	return &selfMetrics{
+		cycleDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_duration_seconds",
+			Help:      "Duration of the last collection cycle",
+		}),
+		cycleProjects: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_projects",
+			Help:      "Number of projects processed during the last collection cycle by status",
+		}, []string{"status"}),
+		cycleAPICalls: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_api_calls",
+			Help:      "Number of Sonar API calls executed during the last collection cycle",
+		}),
+		cycleMeasures: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_measures",
+			Help:      "Number of measures exported during the last collection cycle",
+		}),
+		interval: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "collection_interval_seconds",
+			Help:      "Current delay between collection cycles, stretched while Sonar is under pressure",
+		}),
+		successRatio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_success_ratio",
+			Help:      "Share of projects collected successfully during the last collection cycle",
+		}),
+		budgetExhausted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "budget_exhausted_total",
+			Help:      "Number of collection cycles aborted due to exhausted request budget",
+		}),
+		deadlineExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "cycle_deadline_exceeded_total",
+			Help:      "Number of collection cycles cut short by cycle deadline",
+		}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "components_in_flight",
+			Help:      "Number of components being collected right now",
+		}),
+		queued: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "components_queued",
+			Help:      "Number of components of the running collection cycle waiting for a free worker",
+		}),
+		workerUtilization: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "worker_utilization_ratio",
+			Help:      "Share of concurrency workers busy collecting components",
+		}),
+		consecutiveFailures: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "consecutive_failures",
+			Help:      "Number of collection cycles failed in a row",
+		}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "leader",
+			Help:      "Whether this instance is a leader collecting measures. Always 1 if leader election is disabled",
+		}),
+		quietHours: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "quiet_hours",
+			Help:      "Whether collection is paused by quiet hours",
+		}),
+		tokenExpiresIn: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "token_expires_in_seconds",
+			Help:      "Time left before expiration of Sonar user token",
+		}, []string{"token"}),
+		serverInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "server_info",
+			Help:      "Version and edition of Sonar server. Always 1",
+		}, []string{"version", "edition"}),
+		serverMaintenance: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "server_maintenance",
+			Help:      "Whether Sonar server is under maintenance, e.g. restarting or migrating database, so collection is paused",
+		}),
+		portfolioRating: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "portfolio_rating",
+			Help:      "Rating of portfolio by domain, from 1 (A) to 5 (E)",
+		}, []string{"portfolio", "domain"}),
+		portfolioWorstRating: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "portfolio_worst_project_rating",
+			Help:      "The worst rating of portfolio projects by domain, from 1 (A) to 5 (E)",
+		}, []string{"portfolio", "domain"}),
+		collectorEnabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "collector_enabled",
+			Help:      "Whether optional collector is enabled. Collectors denied access by Sonar are disabled until restart",
+		}, []string{"collector"}),
+		sonarURL: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "sonar_url",
+			Help:      "Whether Sonar URL is in use. Failover URLs are used once the primary one is unreachable",
+		}, []string{"url"}),
+	}
+}
+
+func (m *selfMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.cycleDuration,
+		m.cycleProjects,
+		m.cycleAPICalls,
+		m.cycleMeasures,
+		m.interval,
+		m.successRatio,
+		m.budgetExhausted,
+		m.deadlineExceeded,
+		m.inFlight,
+		m.queued,
+		m.workerUtilization,
+		m.consecutiveFailures,
+		m.leader,
+		m.quietHours,
+		m.tokenExpiresIn,
+		m.serverInfo,
+		m.serverMaintenance,
+		m.portfolioRating,
+		m.portfolioWorstRating,
+		m.collectorEnabled,
+		m.sonarURL,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *selfMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (m *selfMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
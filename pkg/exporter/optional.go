@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"errors"
+	"log"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// Optional collectors are disabled for the rest of the session once Sonar denies them access,
+// so the core measures collection keeps running without logging the same error every cycle
+const (
+	optionalIssueAges      = "issue_ages"
+	optionalResolvedIssues = "resolved_issues"
+	optionalTopRules       = "top_rules"
+	optionalLinks          = "project_links"
+	optionalCustomMeasures = "custom_measures"
+	optionalPullRequests   = "pull_requests"
+	optionalTokens         = "token_expiry"
+	optionalPortfolios     = "portfolios"
+	optionalGateConditions = "gate_conditions"
+	optionalGateInfo       = "gate_info"
+)
+
+// optionalCollectors returns optional collectors by whether they are enabled by configuration
+func (cfg *Config) optionalCollectors() map[string]bool {
+	return map[string]bool{
+		optionalIssueAges:      len(cfg.IssueAgeBuckets) > 0,
+		optionalResolvedIssues: cfg.ResolvedIssues,
+		optionalTopRules:       cfg.TopRules > 0,
+		optionalLinks:          cfg.ProjectLinks,
+		optionalCustomMeasures: cfg.CustomMeasures,
+		optionalPullRequests:   cfg.PullRequests,
+		optionalTokens:         cfg.CheckTokens,
+		optionalPortfolios:     cfg.Portfolios,
+		optionalGateConditions: cfg.GateConditions,
+		optionalGateInfo:       cfg.GateInfo,
+	}
+}
+
+// initOptional enables optional collectors enabled by configuration
+func (c *Collector) initOptional() {
+	c.optional = map[string]bool{}
+	for name, enabled := range c.cfg.optionalCollectors() {
+		if enabled {
+			c.optional[name] = true
+			c.self.collectorEnabled.WithLabelValues(name).Set(1)
+		}
+	}
+}
+
+// optionalEnabled reports whether optional collector is enabled and has not been denied access
+func (c *Collector) optionalEnabled(name string) bool {
+	c.optionalMut.RLock()
+	defer c.optionalMut.RUnlock()
+	return c.optional[name]
+}
+
+// optionalFailed logs failure of optional collector. Collector denied access is disabled for the rest of the session
+func (c *Collector) optionalFailed(name, msg string, err error) {
+	if !errors.Is(err, sonar.ErrForbidden) {
+		log.Printf("%s: %v", msg, err)
+		return
+	}
+
+	c.optionalMut.Lock()
+	enabled := c.optional[name]
+	c.optional[name] = false
+	c.optionalMut.Unlock()
+	// concurrently collected components may be denied at once, so disabling is logged only once
+	if enabled {
+		log.Printf("%s: %v. Collector %s is disabled until restart", msg, err, name)
+		c.self.collectorEnabled.WithLabelValues(name).Set(0)
+	}
+}
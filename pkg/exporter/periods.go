@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// periodIndexLabel tells apart values of measures computed for several periods, e.g. by Sonar prior to 8.0
+const periodIndexLabel = "period_index"
+
+// newCodePeriodIndex is an index of new code period in periods arrays returned by Sonar prior to 8.0
+const newCodePeriodIndex = 1
+
+// periodLabels returns labels of new code series of the period. Every new code series carries all of them,
+// so series of a metric share label names. Values are empty where unknown. Index is left empty, see addPeriods
+func periodLabels(p *sonar.Period) prometheus.Labels {
+	labels := prometheus.Labels{periodIndexLabel: "", periodModeLabel: "", periodParameterLabel: ""}
+	if p != nil {
+		labels[periodModeLabel] = p.Mode
+		labels[periodParameterLabel] = p.Parameter
+	}
+	return labels
+}
+
+// multiPeriod reports whether the new code measure holds values of periods beyond the new code one,
+// returned as periods arrays by Sonar prior to 8.0
+func multiPeriod(measure *sonar.Measure) bool {
+	if measure.Value != "" {
+		return false
+	}
+	for _, p := range measure.Periods {
+		if p.Index != newCodePeriodIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// addPeriods adds series of every period of the measure. Series are labeled with period index,
+// mode and parameter of the period. Index of the new code period is left empty, so its series are the same
+// as ones of Sonar 8.0+ returning a single period and do not change once Sonar is upgraded
+func (pe *PrometheusExporter) addPeriods(snapshot *componentSnapshot, metric *sonar.Metric, measure *sonar.Measure, measures *sonar.Measures) {
+	periods := make(map[int]*sonar.Period, len(measures.Periods))
+	for _, p := range measures.Periods {
+		periods[p.Index] = p
+	}
+	for _, p := range measure.Periods {
+		single := sonar.Measure{Metric: measure.Metric}
+		single.Period.Value = p.Value
+		val, err := pe.conversions.convert(metric.Type, &single)
+		if err != nil {
+			log.Printf("Unable to convert metric %s of period %d: %v", measure.Metric, p.Index, err)
+
+			continue
+		}
+
+		labels := periodLabels(periods[p.Index])
+		if p.Index != newCodePeriodIndex {
+			labels[periodIndexLabel] = strconv.Itoa(p.Index)
+		}
+		names, pairs := labelPairs(withLabels(snapshot.labels.set, labels))
+		snapshot.series = append(snapshot.series, series{
+			desc:   pe.desc(snapshot, metric, "", metric.Description, names),
+			value:  val,
+			labels: pairs,
+		})
+		snapshot.measures++
+	}
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// portfolioDomains are rating metrics of portfolios by domain they rate
+var portfolioDomains = map[string]string{
+	"releasability_rating":   "releasability",
+	"reliability_rating":     "reliability",
+	"security_rating":        "security",
+	"security_review_rating": "security_review",
+	"sqale_rating":           "maintainability",
+}
+
+// distributionSuffix is a suffix of portfolio metrics holding number of projects by rating, e.g. 1=3;2=0;3=1
+const distributionSuffix = "_distribution"
+
+// collectPortfolios exports ratings of portfolios and worst ratings of their projects by domain.
+// Enterprise api/governance_reports endpoints serve PDF reports and subscriptions only, so ratings are read from
+// measures of portfolios instead, and the worst project ratings are inferred from their *_distribution measures.
+// Failures are logged only, so the rest of portfolios are still exported and a failed one keeps previous ratings
+func (c *Collector) collectPortfolios() {
+	portfolios, err := c.sonar.GetPortfolios()
+	if err != nil {
+		c.optionalFailed(optionalPortfolios, "Unable to get portfolios", err)
+		return
+	}
+
+	keys := make([]string, 0, 2*len(portfolioDomains))
+	for key := range portfolioDomains {
+		keys = append(keys, key, key+distributionSuffix)
+	}
+	listed := make(map[string]struct{}, len(portfolios))
+	for _, portfolio := range portfolios {
+		listed[portfolio.Key] = struct{}{}
+		measures, err := c.sonar.GetMeasures(portfolio.Key, keys)
+		if err != nil {
+			log.Printf("Unable to collect portfolio %s, previous ratings are kept: %v", portfolio.Key, err)
+			continue
+		}
+		c.deletePortfolio(portfolio.Key)
+		for _, m := range measures.Component.Measures {
+			if domain, found := portfolioDomains[m.Metric]; found {
+				if rating, err := strconv.ParseFloat(m.Value, 64); err == nil {
+					c.self.portfolioRating.WithLabelValues(portfolio.Key, domain).Set(rating)
+				}
+				continue
+			}
+			domain, found := portfolioDomains[strings.TrimSuffix(m.Metric, distributionSuffix)]
+			if !found {
+				continue
+			}
+			if worst, found := worstRating(m.Value); found {
+				c.self.portfolioWorstRating.WithLabelValues(portfolio.Key, domain).Set(worst)
+			}
+		}
+	}
+	for key := range c.portfolios {
+		if _, found := listed[key]; !found {
+			c.deletePortfolio(key)
+		}
+	}
+	c.portfolios = listed
+}
+
+// deletePortfolio deletes series of the portfolio
+func (c *Collector) deletePortfolio(key string) {
+	for _, domain := range portfolioDomains {
+		c.self.portfolioRating.DeleteLabelValues(key, domain)
+		c.self.portfolioWorstRating.DeleteLabelValues(key, domain)
+	}
+}
+
+// worstRating returns the worst rating having projects in distribution, e.g. 3 for 1=3;2=0;3=1;4=0;5=0
+func worstRating(distribution string) (float64, bool) {
+	worst, found := 0.0, false
+	for _, pair := range strings.Split(distribution, ";") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		rating, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			continue
+		}
+		if count, err := strconv.Atoi(parts[1]); err == nil && count > 0 && rating > worst {
+			worst, found = rating, true
+		}
+	}
+	return worst, found
+}
@@ -0,0 +1,27 @@
+package exporter
+
+import "testing"
+
+func TestWorstRating(t *testing.T) {
+	tests := []struct {
+		distribution string
+		want         float64
+		found        bool
+	}{
+		{distribution: "1=3;2=0;3=1;4=0;5=0", want: 3, found: true},
+		{distribution: "1=0;2=0;3=0;4=0;5=2", want: 5, found: true},
+		{distribution: "1=4", want: 1, found: true},
+		// unordered pairs
+		{distribution: "4=1;1=2", want: 4, found: true},
+		{distribution: "1=0;2=0;3=0;4=0;5=0"},
+		{distribution: ""},
+		{distribution: "A=1;2=x;3;=1"},
+		{distribution: "A=1;2=1", want: 2, found: true},
+	}
+	for _, tt := range tests {
+		got, found := worstRating(tt.distribution)
+		if got != tt.want || found != tt.found {
+			t.Errorf("worst rating of %q is %v, %v, want %v, %v", tt.distribution, got, found, tt.want, tt.found)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"errors"
+	"log"
+	"net/url"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// preflightCheck probes an API endpoint required by enabled collection features
+type preflightCheck struct {
+	// api is a name of the endpoint, e.g. api/issues/search
+	api string
+	// features are flags requiring the endpoint
+	features string
+	// permission is a permission required by the endpoint
+	permission string
+	enabled    bool
+	// perProject checks are probed against a single project
+	perProject bool
+	probe      func(project string) error
+}
+
+// preflightChecks returns checks of API endpoints used by the collector
+func (c *Collector) preflightChecks() []preflightCheck {
+	issues := len(c.cfg.IssueAgeBuckets) > 0 || c.cfg.ResolvedIssues || c.cfg.TopRules > 0
+	return []preflightCheck{
+		{
+			api: "api/components/show", features: "measures", permission: "Browse on projects",
+			enabled: true, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetComponent(project); return err },
+		},
+		{
+			api: "api/measures/component", features: "measures", permission: "Browse on projects",
+			enabled: true, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetMeasures(project, []string{linesMetric}); return err },
+		},
+		{
+			api: "api/issues/search", features: "-issue-age-buckets, -resolved-issues, -top-rules", permission: "Browse on projects",
+			enabled: issues, perProject: true,
+			probe: func(project string) error {
+				_, err := c.sonar.SearchIssues(url.Values{"componentKeys": {project}})
+				return err
+			},
+		},
+		{
+			api: "api/project_links/search", features: "-project-links", permission: "Browse on projects",
+			enabled: c.cfg.ProjectLinks, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetProjectLinks(project); return err },
+		},
+		{
+			api: "api/custom_measures/search", features: "-custom-measures", permission: "Browse on projects",
+			enabled: c.cfg.CustomMeasures, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetCustomMeasures(project); return err },
+		},
+		{
+			api: "api/project_pull_requests/list", features: "-pull-requests", permission: "Browse on projects",
+			enabled: c.cfg.PullRequests, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetPullRequests(project); return err },
+		},
+		{
+			api: "api/qualitygates/project_status", features: "-gate-conditions", permission: "Browse on projects",
+			enabled: c.cfg.GateConditions, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetProjectStatus(project); return err },
+		},
+		{
+			api: "api/qualitygates/get_by_project", features: "-gate-info", permission: "Browse on projects",
+			enabled: c.cfg.GateInfo, perProject: true,
+			probe: func(project string) error { _, err := c.sonar.GetProjectQualityGate(project); return err },
+		},
+		{
+			api: "api/user_tokens/search", features: "-check-token-expiry", permission: "authenticated user",
+			enabled: c.cfg.CheckTokens,
+			probe:   func(string) error { _, err := c.sonar.GetUserTokens(); return err },
+		},
+		{
+			api: "api/components/search?qualifiers=VW", features: "-portfolios", permission: "Browse on portfolios",
+			enabled: c.cfg.Portfolios,
+			probe:   func(string) error { _, err := c.sonar.GetPortfolios(); return err },
+		},
+	}
+}
+
+// preflight probes API endpoints required by enabled features and logs the ones the user lacks permissions for,
+// so missing permissions are reported once on start instead of failing every cycle.
+// Per project endpoints are probed against the first project visible to the user
+func (c *Collector) preflight() {
+	projects, err := c.listComponents()
+	if err != nil {
+		log.Printf("Preflight: unable to list projects, check 'Browse' permission on projects: %v", err)
+		return
+	}
+	project := ""
+	if len(projects) > 0 {
+		project = projects[0].Key
+	}
+
+	failed := 0
+	for _, check := range c.preflightChecks() {
+		if !check.enabled || (check.perProject && project == "") {
+			continue
+		}
+		err := check.probe(project)
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, sonar.ErrUnauthorized), errors.Is(err, sonar.ErrForbidden):
+			log.Printf("Preflight: %s required by %s is denied, the user lacks '%s' permission: %v",
+				check.api, check.features, check.permission, err)
+		default:
+			log.Printf("Preflight: %s required by %s failed: %v", check.api, check.features, err)
+		}
+		failed++
+	}
+	if failed == 0 {
+		log.Println("Preflight: all required API endpoints are accessible")
+	}
+}
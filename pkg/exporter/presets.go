@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// metricPresets are curated sets of metrics by preset name, keeping cardinality low compared to all metrics of Sonar
+var metricPresets = map[string][]string{
+	"core": {
+		"coverage", "bugs", "vulnerabilities", "code_smells", "duplicated_lines_density", "alert_status",
+	},
+	"reliability": {
+		"bugs", "new_bugs", "reliability_rating", "reliability_remediation_effort", "alert_status",
+	},
+	"security": {
+		"vulnerabilities", "new_vulnerabilities", "security_rating", "security_hotspots",
+		"security_hotspots_reviewed", "security_review_rating", "alert_status",
+	},
+	"maintainability": {
+		"code_smells", "new_code_smells", "sqale_index", "sqale_rating", "sqale_debt_ratio",
+		"cognitive_complexity", "duplicated_lines_density", "alert_status",
+	},
+}
+
+// MetricPreset returns keys of metrics of the preset
+func MetricPreset(name string) ([]string, error) {
+	metrics, found := metricPresets[name]
+	if !found {
+		return nil, fmt.Errorf("unknown metrics preset %q, one of %s expected", name, strings.Join(MetricPresets(), ", "))
+	}
+	return append([]string(nil), metrics...), nil
+}
+
+// MetricPresets returns names of metric presets
+func MetricPresets() []string {
+	names := make([]string, 0, len(metricPresets))
+	for name := range metricPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+const (
+	// PriorityRecent puts recently analyzed projects first
+	PriorityRecent = "recent"
+	// PriorityTagPrefix prefixes a tag putting tagged projects first, e.g. tag:critical
+	PriorityTagPrefix = "tag:"
+)
+
+// ParseProjectPriority parses comma separated priorities of projects, e.g. tag:critical,recent
+func ParseProjectPriority(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	priority := strings.Split(s, ",")
+	for _, p := range priority {
+		if p != PriorityRecent && (!strings.HasPrefix(p, PriorityTagPrefix) || p == PriorityTagPrefix) {
+			return nil, fmt.Errorf("unknown project priority: %s", p)
+		}
+	}
+	return priority, nil
+}
+
+// prioritizeComponents orders components collected during the cycle by ProjectPriority, so the most relevant
+// ones are refreshed even if the cycle is cut short. Priorities are applied in order using metadata of previous
+// cycles. Components without metadata, i.e. new ones, go first since they have no measures at all
+func (c *Collector) prioritizeComponents(components []*sonar.ComponentInfo) {
+	if len(c.cfg.ProjectPriority) == 0 {
+		return
+	}
+	c.componentsMut.Lock()
+	known := make(map[string]*sonar.Component, len(components))
+	for _, component := range components {
+		if cached, found := c.components[component.Key]; found {
+			known[component.Key] = cached
+		}
+	}
+	c.componentsMut.Unlock()
+
+	sort.SliceStable(components, func(i, j int) bool {
+		left, right := known[components[i].Key], known[components[j].Key]
+		if left == nil || right == nil {
+			return left == nil && right != nil
+		}
+		for _, p := range c.cfg.ProjectPriority {
+			if p == PriorityRecent {
+				if l, r := time.Time(left.AnalysisDate), time.Time(right.AnalysisDate); !l.Equal(r) {
+					return l.After(r)
+				}
+				continue
+			}
+			tag := strings.TrimPrefix(p, PriorityTagPrefix)
+			if l, r := hasTag(left, tag), hasTag(right, tag); l != r {
+				return l
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,795 @@
+// Package exporter converts SonarQube measures into Prometheus metrics
+package exporter
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+const (
+	namespace      = "sonar"
+	componentLabel = "component"
+)
+
+const (
+	levelType  = "LEVEL"
+	levelLabel = "level"
+	// gateMetric is a key of quality gate status metric
+	gateMetric = "alert_status"
+	// linesMetric is a key of lines of code metric
+	linesMetric = "ncloc"
+
+	periodModeLabel      = "period_mode"
+	periodParameterLabel = "period_parameter"
+)
+
+var promNamePattern = regexp.MustCompile("[^a-zA-Z_:]")
+
+// dataAgeDesc describes age of components' measures
+var dataAgeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "component", "data_age_seconds"),
+	"Time passed since measures of the component were collected", []string{componentLabel}, nil)
+
+// ingestionLagDesc describes time passed between analysis of components and collection of its measures
+var ingestionLagDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, selfSubsystem, "ingestion_lag_seconds"),
+	"Time passed between the last analysis of the component and collection of its measures", []string{componentLabel}, nil)
+
+// coverageGapMetric is a pseudo metric of difference between overall and new code coverage
+var coverageGapMetric = &sonar.Metric{Key: "coverage_gap", Description: "Overall coverage minus coverage on new code"}
+
+// neverAnalyzedMetric and emptyMetric are pseudo metrics flagging provisioned projects never analyzed
+// and analyzed projects having no lines of code, e.g. abandoned ones to clean up
+var (
+	neverAnalyzedMetric = &sonar.Metric{Key: "project_never_analyzed", Description: "Whether the project has never been analyzed"}
+	emptyMetric         = &sonar.Metric{Key: "project_empty", Description: "Whether the analyzed project has no lines of code"}
+)
+
+// analysisMetric is a pseudo metric of component's last analysis time, labeled the same way as measures
+var analysisMetric = &sonar.Metric{Key: "component_analysis_timestamp_seconds", Description: "Time of the last analysis of the component"}
+
+// ExporterConfig holds settings of measures conversion
+type ExporterConfig struct {
+	// LegacyNames names series after their components, e.g. sonar_my_project_coverage{component="my-project"}
+	// instead of sonar_coverage{component="my-project"}, for dashboards built for earlier versions of the exporter
+	LegacyNames bool
+	// LabelSeparator is used to convert project tags into labels, e.g. 'key#value'.
+	// Empty separator disables conversion
+	LabelSeparator string
+	// Conversions defines how measures are converted to numbers. Nil means DefaultConversions
+	Conversions Conversions
+	// MetricTTL is a number of collection cycles series are kept for after they were reported last time.
+	// Zero keeps series until their components disappear from Sonar
+	MetricTTL int
+	// MaxStaleness hides series of components whose measures were collected earlier than that. Zero disables the check
+	MaxStaleness time.Duration
+	// LabelLimits are max numbers of distinct values of labels converted from tags. Values beyond the limit
+	// are replaced with 'other'
+	LabelLimits map[string]int
+	// LabelPolicies define handling of components lacking labels. Components lacking labels without policy
+	// are exported without them
+	LabelPolicies LabelPolicies
+	// Aliases are historical keys of renamed components by their current keys
+	Aliases Aliases
+}
+
+// PrometheusExporter exposes reported Sonar measures as Prometheus gauges.
+// Each component's measures are kept as an immutable snapshot which is replaced
+// as a whole on every report, so concurrent reports and scrapes never observe
+// partially updated label sets
+type PrometheusExporter struct {
+	labelSeparator string
+	legacyNames    bool
+	conversions    Conversions
+	// levelStates are known values of LEVEL metrics
+	levelStates  []string
+	metricTTL    uint64
+	maxStaleness time.Duration
+
+	mut     sync.RWMutex
+	metrics map[string]*sonar.Metric
+	// definitions are all metrics defined in Sonar, including ones not exported
+	definitions []*sonar.Metric
+	// cycle is a number of the current collection cycle
+	cycle      uint64
+	components map[string]*componentSnapshot
+
+	// families are metrics reported at least once during last metricTTL cycles
+	familiesMut sync.Mutex
+	families    map[string]*metricFamily
+
+	// gateTransitions counts changes of quality gate status observed between reports
+	gateTransitions *prometheus.CounterVec
+	limiter         *labelLimiter
+	labelPolicies   LabelPolicies
+	aliases         Aliases
+	// labelMismatches counts reports of components lacking labels declared by label policies
+	labelMismatches *prometheus.CounterVec
+}
+
+// metricFamily holds name and descriptors of a metric.
+// Created once a measure of the metric is reported for the first time
+type metricFamily struct {
+	name string
+	// descs caches descriptors by name suffix and label names
+	descs map[string]*prometheus.Desc
+	// reported is a number of the cycle metric was reported last time in
+	reported uint64
+}
+
+// componentSnapshot holds measures of a single component. Never mutated once created
+type componentSnapshot struct {
+	labels componentLabels
+	series []series
+	// measures is a number of measures exported
+	measures int
+	// reported is a number of the cycle snapshot was reported in
+	reported uint64
+	// collected is a time measures were collected at
+	collected time.Time
+	// analyzed is a time of the last analysis. Zero if never analyzed
+	analyzed time.Time
+	// gate is a quality gate status. Empty if unknown
+	gate string
+	// transitions are from and to statuses of quality gate transitions counted for the component,
+	// so their counters are deleted along with the component
+	transitions [][2]string
+	// values are overall values of metrics kept for rollups and reports, see keptMetric
+	values map[string]float64
+	// languages are lines of code by language
+	languages map[string]float64
+	// alias is a historical key of renamed component and aliasSeries are series labeled with it.
+	// Series are exported under both keys if aliasBoth is true
+	alias       string
+	aliasSeries []series
+	aliasBoth   bool
+	// merged is true if the component is a logical one merged from projects, see Merges
+	merged bool
+}
+
+// componentLabels are label sets of component's series precomputed once
+// and shared between snapshots while component's tags and period stay the same
+type componentLabels struct {
+	period sonar.Period
+	// missing are names of labels declared by label policies the component lacks
+	missing []string
+	// dropped is true if component lacks a label required by label policies, so its series are not exported
+	dropped bool
+	// subsystem is a component name series are prefixed with in legacy naming. Empty otherwise
+	subsystem string
+
+	// set is a label set of component's series: labels converted from tags with label policies and limits
+	// applied along with the component label. Never mutated, series extending it get copies
+	set   prometheus.Labels
+	names []string
+	pairs []*dto.LabelPair
+	// periodSet, periodNames and periodPairs extend labels of new code measures
+	// with period the values are computed for
+	periodSet   prometheus.Labels
+	periodNames []string
+	periodPairs []*dto.LabelPair
+	// levelNames and levelPairs are labels of LEVEL state sets, one set of pairs per state
+	levelNames []string
+	levelPairs [][]*dto.LabelPair
+}
+
+// series is a single precomputed time series. Implements prometheus.Metric,
+// so scrapes reuse label pairs shared between series instead of building them every time
+type series struct {
+	desc   *prometheus.Desc
+	value  float64
+	labels []*dto.LabelPair
+}
+
+// Desc implements prometheus.Metric
+func (s *series) Desc() *prometheus.Desc {
+	return s.desc
+}
+
+// Write implements prometheus.Metric
+func (s *series) Write(m *dto.Metric) error {
+	val := s.value
+	m.Label = s.labels
+	m.Gauge = &dto.Gauge{Value: &val}
+	return nil
+}
+
+// NewPrometheusExporter creates new exporter
+func NewPrometheusExporter(cfg ExporterConfig) *PrometheusExporter {
+	if cfg.Conversions == nil {
+		cfg.Conversions = DefaultConversions()
+	}
+	return &PrometheusExporter{
+		labelSeparator: cfg.LabelSeparator,
+		legacyNames:    cfg.LegacyNames,
+		conversions:    cfg.Conversions,
+		levelStates:    cfg.Conversions.states(levelType),
+		metricTTL:      uint64(cfg.MetricTTL),
+		maxStaleness:   cfg.MaxStaleness,
+		metrics:        map[string]*sonar.Metric{},
+		components:     map[string]*componentSnapshot{},
+		families:       map[string]*metricFamily{},
+		gateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "quality_gate_transitions_total",
+			Help:      "Number of quality gate status changes observed by the exporter",
+		}, []string{componentLabel, "from", "to"}),
+		limiter:       newLabelLimiter(cfg.LabelLimits),
+		labelPolicies: cfg.LabelPolicies,
+		aliases:       cfg.Aliases,
+		labelMismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: selfSubsystem,
+			Name:      "label_mismatch_total",
+			Help:      "Number of component reports lacking labels declared by label policies",
+		}, []string{componentLabel}),
+	}
+}
+
+// registerMetrics remembers metric definitions and returns keys of supported metrics.
+// Metrics themselves are created lazily once their measures are reported
+func (pe *PrometheusExporter) registerMetrics(metrics []*sonar.Metric) []string {
+	pe.mut.Lock()
+	defer pe.mut.Unlock()
+	pe.definitions = metrics
+
+	// metric names
+	var mNames []string
+	for _, m := range metrics {
+		_, distribution := distributionLabels[m.Key]
+		if !distribution && !pe.conversions.supported(m.Type) {
+			continue
+		}
+		pe.metrics[m.Key] = m
+		mNames = append(mNames, m.Key)
+	}
+	return mNames
+}
+
+// learnMetrics registers definitions of metrics returned along with measures unless registered already
+func (pe *PrometheusExporter) learnMetrics(metrics []*sonar.Metric) {
+	pe.mut.Lock()
+	defer pe.mut.Unlock()
+	for _, m := range metrics {
+		if _, found := pe.metrics[m.Key]; found {
+			continue
+		}
+		_, distribution := distributionLabels[m.Key]
+		if !distribution && !pe.conversions.supported(m.Type) {
+			continue
+		}
+		pe.metrics[m.Key] = m
+		pe.definitions = append(pe.definitions, m)
+	}
+}
+
+// reporter accepts measures of components
+type reporter interface {
+	// report reports component's measures along with series collected from other APIs.
+	// Returns number of exported measures
+	report(component *sonar.Component, measures *sonar.Measures, extras []extraSeries) int
+}
+
+// extraSeries is a series of component collected in addition to measures, e.g. from issues API
+type extraSeries struct {
+	// metric is a pseudo metric providing series name and help
+	metric *sonar.Metric
+	// labels extend component's labels. Labels of the same names as component's ones replace them
+	labels prometheus.Labels
+	value  float64
+}
+
+// batch accumulates snapshots reported during a collection cycle. Snapshots are exposed
+// all at once when the batch is committed, so scrapes never observe a mix of two cycles
+type batch struct {
+	pe *PrometheusExporter
+
+	mut        sync.Mutex
+	components map[string]*componentSnapshot
+}
+
+// newBatch starts accumulation of collection cycle's snapshots
+func (pe *PrometheusExporter) newBatch() *batch {
+	return &batch{pe: pe, components: map[string]*componentSnapshot{}}
+}
+
+// report implements reporter. Measures are exposed once the batch is committed
+func (b *batch) report(component *sonar.Component, measures *sonar.Measures, extras []extraSeries) int {
+	snapshot := b.pe.snapshot(component, measures, extras)
+
+	b.mut.Lock()
+	b.components[component.Key] = snapshot
+	b.mut.Unlock()
+	return snapshot.measures
+}
+
+// Report immediately replaces component's measures with provided ones.
+// Returns number of exported measures
+func (pe *PrometheusExporter) Report(component *sonar.Component, measures *sonar.Measures) int {
+	return pe.report(component, measures, nil)
+}
+
+// report implements reporter
+func (pe *PrometheusExporter) report(component *sonar.Component, measures *sonar.Measures, extras []extraSeries) int {
+	snapshot := pe.snapshot(component, measures, extras)
+
+	pe.mut.Lock()
+	pe.components[component.Key] = snapshot
+	pe.mut.Unlock()
+
+	return snapshot.measures
+}
+
+// snapshot builds snapshot of component's measures and extra series
+func (pe *PrometheusExporter) snapshot(component *sonar.Component, measures *sonar.Measures, extras []extraSeries) *componentSnapshot {
+	pe.mut.RLock()
+	defer pe.mut.RUnlock()
+
+	prev := pe.components[component.Key]
+	snapshot := &componentSnapshot{
+		labels:    pe.componentLabels(component, measures.NewCodePeriod(), prev),
+		series:    make([]series, 0, len(measures.Component.Measures)),
+		reported:  pe.cycle,
+		collected: time.Now(),
+		analyzed:  component.AnalysisDate.Time(),
+	}
+	if prev != nil {
+		snapshot.transitions = prev.transitions
+	}
+	if len(snapshot.labels.missing) > 0 {
+		pe.labelMismatches.WithLabelValues(component.Key).Inc()
+	}
+	if snapshot.labels.dropped {
+		return snapshot
+	}
+	if !component.AnalysisDate.IsZero() {
+		snapshot.series = append(snapshot.series, series{
+			desc:   pe.desc(snapshot, analysisMetric, "", analysisMetric.Description, snapshot.labels.names),
+			value:  float64(component.AnalysisDate.Time().Unix()),
+			labels: snapshot.labels.pairs,
+		})
+	}
+	// definitions returned along with measures are up to date, e.g. once type of custom metric is changed
+	types := make(map[string]string, len(measures.Metrics))
+	for _, m := range measures.Metrics {
+		types[m.Key] = m.Type
+	}
+	for _, measure := range measures.Component.Measures {
+		metric, found := pe.metrics[measure.Metric]
+		if !found {
+			log.Printf("NO METRIC FOUND: %s", measure.Metric)
+
+			continue
+		}
+		if mType, found := types[metric.Key]; found && mType != metric.Type && pe.conversions.supported(mType) {
+			updated := *metric
+			updated.Type = mType
+			metric = &updated
+		}
+
+		if _, distribution := distributionLabels[metric.Key]; distribution {
+			values, err := pe.addDistribution(snapshot, metric, measure)
+			if err != nil {
+				log.Printf("Unable to convert metric %s: %v", measure.Metric, err)
+
+				continue
+			}
+			if metric.Key == languagesMetric && measure.Value != "" {
+				snapshot.languages = values
+			}
+
+			continue
+		}
+		if multiPeriod(measure) {
+			pe.addPeriods(snapshot, metric, measure, measures)
+
+			continue
+		}
+
+		val, err := pe.conversions.convert(metric.Type, measure)
+		if err != nil {
+			log.Printf("Unable to convert metric %s: %v", measure.Metric, err)
+
+			continue
+		}
+		pe.addSeries(snapshot, metric, measure, val)
+		if metric.Key == gateMetric {
+			snapshot.gate = measureValue(measure)
+		}
+		if keptMetric(metric.Key) && measure.Value != "" {
+			if snapshot.values == nil {
+				snapshot.values = map[string]float64{}
+			}
+			snapshot.values[metric.Key] = val
+		}
+	}
+
+	for i := range extras {
+		pe.addExtra(snapshot, &extras[i])
+	}
+	if gap, found := coverageGap(measures); found {
+		pe.addExtra(snapshot, &extraSeries{metric: coverageGapMetric, value: gap})
+	}
+	pe.addHousekeeping(snapshot, component, measures)
+	pe.addAlias(snapshot, component.Key)
+
+	if prev != nil && prev.gate != "" && snapshot.gate != "" && prev.gate != snapshot.gate {
+		pe.gateTransitions.WithLabelValues(component.Key, prev.gate, snapshot.gate).Inc()
+		snapshot.transitions = withTransition(snapshot.transitions, [2]string{prev.gate, snapshot.gate})
+	}
+	return snapshot
+}
+
+// withTransition returns transitions extended with the transition unless it is known already.
+// Transitions are copied, so ones of previous snapshots are never mutated
+func withTransition(transitions [][2]string, transition [2]string) [][2]string {
+	for _, t := range transitions {
+		if t == transition {
+			return transitions
+		}
+	}
+	extended := make([][2]string, len(transitions), len(transitions)+1)
+	copy(extended, transitions)
+	return append(extended, transition)
+}
+
+// addSeries adds series of the measure to the snapshot
+func (pe *PrometheusExporter) addSeries(snapshot *componentSnapshot, metric *sonar.Metric, measure *sonar.Measure, val float64) {
+	labels := &snapshot.labels
+
+	labelNames, labelPairs := labels.names, labels.pairs
+	if measure.IsNewCode() {
+		labelNames, labelPairs = labels.periodNames, labels.periodPairs
+	}
+	snapshot.series = append(snapshot.series, series{
+		desc:   pe.desc(snapshot, metric, "", metric.Description, labelNames),
+		value:  val,
+		labels: labelPairs,
+	})
+	snapshot.measures++
+
+	// LEVEL metrics are exported as state sets as well, e.g. sonar_alert_status_level{level="ERROR"} 1
+	if metric.Type != levelType {
+		return
+	}
+	desc := pe.desc(snapshot, metric, "_"+levelLabel, metric.Description+" Exported as a state set", labels.levelNames)
+	level := measureValue(measure)
+	for i, state := range pe.levelStates {
+		var stateVal float64
+		if state == level {
+			stateVal = 1
+		}
+		snapshot.series = append(snapshot.series, series{desc: desc, value: stateVal, labels: labels.levelPairs[i]})
+	}
+}
+
+// addExtra adds extra series to the snapshot
+func (pe *PrometheusExporter) addExtra(snapshot *componentSnapshot, extra *extraSeries) {
+	names, pairs := snapshot.labels.names, snapshot.labels.pairs
+	if len(extra.labels) > 0 {
+		names, pairs = labelPairs(withLabels(snapshot.labels.set, extra.labels))
+	}
+	snapshot.series = append(snapshot.series, series{
+		desc:   pe.desc(snapshot, extra.metric, "", extra.metric.Description, names),
+		value:  extra.value,
+		labels: pairs,
+	})
+}
+
+// addHousekeeping flags never analyzed and empty projects. Emptiness is judged by lines of code,
+// so it is not flagged unless the metric is collected
+func (pe *PrometheusExporter) addHousekeeping(snapshot *componentSnapshot, component *sonar.Component, measures *sonar.Measures) {
+	neverAnalyzed := component.AnalysisDate.IsZero()
+	pe.addExtra(snapshot, &extraSeries{metric: neverAnalyzedMetric, value: boolValue(neverAnalyzed)})
+	if _, found := pe.metrics[linesMetric]; !found || neverAnalyzed {
+		return
+	}
+	empty := true
+	for _, measure := range measures.Component.Measures {
+		if measure.Metric == linesMetric && measure.Value != "" && measure.Value != "0" {
+			empty = false
+		}
+	}
+	pe.addExtra(snapshot, &extraSeries{metric: emptyMetric, value: boolValue(empty)})
+}
+
+// boolValue converts flag to 1 or 0
+func boolValue(flag bool) float64 {
+	if flag {
+		return 1
+	}
+	return 0
+}
+
+// coverageGap returns overall coverage minus new code coverage if both are measured
+func coverageGap(measures *sonar.Measures) (float64, bool) {
+	var coverage, newCoverage string
+	for _, measure := range measures.Component.Measures {
+		switch measure.Metric {
+		case "coverage":
+			coverage = measure.Value
+		case "new_coverage":
+			newCoverage = measureValue(measure)
+		}
+	}
+	if coverage == "" || newCoverage == "" {
+		return 0, false
+	}
+	overall, err := strconv.ParseFloat(coverage, 64)
+	if err != nil {
+		return 0, false
+	}
+	leak, err := strconv.ParseFloat(newCoverage, 64)
+	if err != nil {
+		return 0, false
+	}
+	return overall - leak, true
+}
+
+// componentLabels builds label sets of the component. Label sets of previous snapshot
+// are reused if the resulting labels and period have not changed, regardless of order of tags
+func (pe *PrometheusExporter) componentLabels(component *sonar.Component, period *sonar.Period, prev *componentSnapshot) componentLabels {
+	var p sonar.Period
+	if period != nil {
+		p = *period
+	}
+	// limits are applied on every report, so values admitted by the limiter do not expire
+	set := pe.tagsToLabels(component.Tags)
+	missing, keep := pe.labelPolicies.apply(set)
+	dropped := !keep
+	if !dropped {
+		pe.limiter.limit(set, pe.cycle)
+	}
+	set[componentLabel] = component.Key
+	if prev != nil && prev.labels.period.Mode == p.Mode && prev.labels.period.Parameter == p.Parameter &&
+		equalLabels(prev.labels.set, set) && equalStrings(prev.labels.missing, missing) {
+		return prev.labels
+	}
+
+	cl := componentLabels{period: p, missing: missing, dropped: dropped, set: set}
+	if pe.legacyNames {
+		cl.subsystem = pe.cleanupName(component.Key)
+	}
+	if dropped {
+		log.Printf("Component %s lacks labels %s required by label policies, its measures are not exported",
+			component.Key, strings.Join(missing, ", "))
+	}
+	cl.names, cl.pairs = labelPairs(set)
+
+	cl.levelPairs = make([][]*dto.LabelPair, len(pe.levelStates))
+	for i, state := range pe.levelStates {
+		cl.levelNames, cl.levelPairs[i] = labelPairs(withLabels(set, prometheus.Labels{levelLabel: state}))
+	}
+
+	cl.periodSet = withLabels(set, periodLabels(period))
+	cl.periodNames, cl.periodPairs = labelPairs(cl.periodSet)
+	return cl
+}
+
+// desc returns cached descriptor of the metric with name suffix for series of the snapshot and marks the metric
+// as reported in the snapshot's cycle. Creates the metric if it has not been reported yet
+func (pe *PrometheusExporter) desc(snapshot *componentSnapshot, metric *sonar.Metric, suffix, help string, labelNames []string) *prometheus.Desc {
+	cycle, subsystem := snapshot.reported, snapshot.labels.subsystem
+	key := subsystem + "\xff" + suffix + "\xff" + strings.Join(labelNames, "\xff")
+
+	pe.familiesMut.Lock()
+	defer pe.familiesMut.Unlock()
+	family, found := pe.families[metric.Key]
+	if !found {
+		family = &metricFamily{
+			name:  prometheus.BuildFQName(namespace, "", pe.cleanupName(metric.Key)),
+			descs: map[string]*prometheus.Desc{},
+		}
+		pe.families[metric.Key] = family
+	}
+	if cycle > family.reported {
+		family.reported = cycle
+	}
+
+	desc, found := family.descs[key]
+	if !found {
+		name := family.name
+		if subsystem != "" {
+			name = prometheus.BuildFQName(namespace, subsystem, pe.cleanupName(metric.Key))
+		}
+		desc = prometheus.NewDesc(name+suffix, help, labelNames, nil)
+		family.descs[key] = desc
+	}
+	return desc
+}
+
+// retain drops measures of all components except provided ones
+func (pe *PrometheusExporter) retain(keys map[string]struct{}) {
+	pe.mut.Lock()
+	defer pe.mut.Unlock()
+
+	for key, snapshot := range pe.components {
+		if _, found := keys[key]; !found {
+			pe.retire(key, snapshot)
+		}
+	}
+}
+
+// retire drops measures of the component along with its per-component counters.
+// Must be called with the mutex locked
+func (pe *PrometheusExporter) retire(key string, snapshot *componentSnapshot) {
+	delete(pe.components, key)
+	pe.labelMismatches.DeleteLabelValues(key)
+	for _, t := range snapshot.transitions {
+		pe.gateTransitions.DeleteLabelValues(key, t[0], t[1])
+	}
+}
+
+// apply exposes measures of the batch without finishing collection cycle.
+// Components collected later than the batch keep their measures
+func (pe *PrometheusExporter) apply(b *batch) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	pe.mut.Lock()
+	defer pe.mut.Unlock()
+
+	for key, snapshot := range b.components {
+		if prev, found := pe.components[key]; !found || !prev.collected.After(snapshot.collected) {
+			pe.components[key] = snapshot
+		}
+	}
+}
+
+// commit atomically replaces exposed measures with ones of the batch and finishes collection cycle.
+// Components absent from the batch or collected later than the batch, e.g. refreshed on demand,
+// keep their previous measures unless they are not in the provided keys or have not been reported for metricTTL cycles
+func (pe *PrometheusExporter) commit(b *batch, keys map[string]struct{}) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	pe.mut.Lock()
+	defer pe.mut.Unlock()
+
+	components := make(map[string]*componentSnapshot, len(keys))
+	for key := range keys {
+		snapshot, found := b.components[key]
+		if prev, exposed := pe.components[key]; exposed && (!found || prev.collected.After(snapshot.collected)) {
+			snapshot, found = prev, true
+		}
+		if found {
+			components[key] = snapshot
+		}
+	}
+	// components of the batch may be counted as mismatching though never exposed
+	for _, retired := range []map[string]*componentSnapshot{pe.components, b.components} {
+		for key, snapshot := range retired {
+			if _, found := components[key]; !found {
+				pe.retire(key, snapshot)
+			}
+		}
+	}
+	pe.components = components
+
+	pe.cycle++
+	if pe.metricTTL == 0 {
+		return
+	}
+	for key, snapshot := range pe.components {
+		if pe.cycle-snapshot.reported > pe.metricTTL {
+			pe.retire(key, snapshot)
+		}
+	}
+	pe.limiter.expire(pe.cycle, pe.metricTTL)
+
+	pe.familiesMut.Lock()
+	defer pe.familiesMut.Unlock()
+	for key, family := range pe.families {
+		if pe.cycle-family.reported > pe.metricTTL {
+			delete(pe.families, key)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector. Sends no descriptors since
+// set of labels depends on component's tags, so the exporter is unchecked
+func (pe *PrometheusExporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (pe *PrometheusExporter) Collect(ch chan<- prometheus.Metric) {
+	pe.gateTransitions.Collect(ch)
+	pe.limiter.overflows.Collect(ch)
+	pe.labelMismatches.Collect(ch)
+
+	pe.mut.RLock()
+	defer pe.mut.RUnlock()
+
+	now := time.Now()
+	for key, snapshot := range pe.components {
+		if snapshot.labels.dropped {
+			continue
+		}
+		// historical key still used by another component is skipped, so series never clash
+		aliased := false
+		if snapshot.alias != "" {
+			if _, taken := pe.components[snapshot.alias]; !taken {
+				pe.collectComponent(ch, now, snapshot.alias, snapshot, snapshot.aliasSeries)
+				aliased = true
+			}
+		}
+		if !aliased || snapshot.aliasBoth {
+			pe.collectComponent(ch, now, key, snapshot, snapshot.series)
+		}
+	}
+}
+
+// collectComponent sends series of the component exported under the key
+func (pe *PrometheusExporter) collectComponent(ch chan<- prometheus.Metric, now time.Time, key string, snapshot *componentSnapshot, series []series) {
+	age := now.Sub(snapshot.collected)
+	ch <- prometheus.MustNewConstMetric(dataAgeDesc, prometheus.GaugeValue, age.Seconds(), key)
+	if !snapshot.analyzed.IsZero() {
+		lag := snapshot.collected.Sub(snapshot.analyzed)
+		ch <- prometheus.MustNewConstMetric(ingestionLagDesc, prometheus.GaugeValue, lag.Seconds(), key)
+	}
+	if pe.maxStaleness > 0 && age > pe.maxStaleness {
+		return
+	}
+	for i := range series {
+		ch <- &series[i]
+	}
+}
+
+func (pe *PrometheusExporter) cleanupName(n string) string {
+	return promNamePattern.ReplaceAllString(n, "_")
+}
+
+// tagsToLabels converts Sonar's project tags to Prometheus's labels
+// tags are supposed to be separated with separator, e.g. key#value. Tags are converted in sorted order
+// and the first value of a label wins, so the label set does not depend on order tags are returned in.
+// Tags of invalid or reserved label names are skipped
+func (pe *PrometheusExporter) tagsToLabels(tags []string) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if pe.labelSeparator == "" {
+		return labels
+	}
+	sorted := make([]string, len(tags))
+	copy(sorted, tags)
+	sort.Strings(sorted)
+	for _, tag := range sorted {
+		parts := strings.Split(tag, pe.labelSeparator)
+		if len(parts) != 2 {
+			continue
+		}
+		name := pe.cleanupName(parts[0])
+		if _, found := labels[name]; found || ValidateLabelName(name) != nil {
+			continue
+		}
+		labels[name] = parts[1]
+	}
+	return labels
+}
+
+// equalStrings reports whether slices have the same elements in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nolint:deadcode
+func getMetric(name string, metrics []*sonar.Metric) *sonar.Metric {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	log.Printf("NO METRIC FOUND: %s", name)
+	return nil
+}
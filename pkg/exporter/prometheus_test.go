@@ -0,0 +1,294 @@
+package exporter
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// benchComponents is a number of components reported by benchmarks
+const benchComponents = 1000
+
+// benchExporter creates exporter knowing a typical set of metrics
+func benchExporter() *PrometheusExporter {
+	pe := NewPrometheusExporter(ExporterConfig{LabelSeparator: "#"})
+	pe.registerMetrics([]*sonar.Metric{
+		{Key: "coverage", Type: "PERCENT"},
+		{Key: "bugs", Type: "INT"},
+		{Key: "vulnerabilities", Type: "INT"},
+		{Key: "code_smells", Type: "INT"},
+		{Key: "ncloc", Type: "INT"},
+		{Key: "sqale_rating", Type: "RATING"},
+		{Key: "alert_status", Type: "LEVEL"},
+		{Key: "new_coverage", Type: "PERCENT"},
+	})
+	return pe
+}
+
+// benchMeasures creates tagged components along with their measures
+func benchMeasures() ([]*sonar.Component, []*sonar.Measures) {
+	components := make([]*sonar.Component, benchComponents)
+	measures := make([]*sonar.Measures, benchComponents)
+	for i := range components {
+		key := fmt.Sprintf("project-%04d", i)
+		c := &sonar.Component{Tags: []string{"team#team-" + strconv.Itoa(i%20), "env#prod", "misc"}}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		components[i] = c
+
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		for _, pair := range [][2]string{
+			{"coverage", "81.5"}, {"bugs", strconv.Itoa(i)}, {"vulnerabilities", "2"}, {"code_smells", "40"},
+			{"ncloc", "12000"}, {"sqale_rating", "1.0"}, {"alert_status", "OK"},
+		} {
+			m.Component.Measures = append(m.Component.Measures, &sonar.Measure{Metric: pair[0], Value: pair[1]})
+		}
+		newCoverage := &sonar.Measure{Metric: "new_coverage"}
+		newCoverage.Period.Value = "75.0"
+		m.Component.Measures = append(m.Component.Measures, newCoverage)
+		measures[i] = m
+	}
+	return components, measures
+}
+
+func TestNewCodeLabelNames(t *testing.T) {
+	pe := benchExporter()
+	newCoverage := func(key string) (*sonar.Component, *sonar.Measures) {
+		c := &sonar.Component{}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		m.Component.Measures = []*sonar.Measure{{Metric: "new_coverage"}}
+		return c, m
+	}
+
+	c, m := newCoverage("with-period")
+	m.Period = &sonar.Period{Mode: "days", Parameter: "30"}
+	m.Component.Measures[0].Period.Value = "80"
+	pe.Report(c, m)
+
+	c, m = newCoverage("without-period")
+	m.Component.Measures[0].Period.Value = "70"
+	pe.Report(c, m)
+
+	c, m = newCoverage("several-periods")
+	m.Periods = []*sonar.Period{{Index: 1, Mode: "previous_version"}, {Index: 2, Mode: "days", Parameter: "30"}}
+	m.Component.Measures[0].Periods = append(m.Component.Measures[0].Periods,
+		struct {
+			Index int    `json:"index"`
+			Value string `json:"value"`
+		}{Index: 1, Value: "60"},
+		struct {
+			Index int    `json:"index"`
+			Value string `json:"value"`
+		}{Index: 3, Value: "50"})
+	pe.Report(c, m)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(pe)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "component,period_index,period_mode,period_parameter"
+	found := 0
+	for _, family := range families {
+		if family.GetName() != "sonar_new_coverage" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var names []string
+			for _, pair := range m.GetLabel() {
+				names = append(names, pair.GetName())
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, ","); got != want {
+				t.Errorf("series %v labeled with %s, want %s", m.GetLabel(), got, want)
+			}
+			found++
+		}
+	}
+	if found != 4 {
+		t.Errorf("%d series of sonar_new_coverage, want 4", found)
+	}
+}
+
+// newCodeSeries gathers series of sonar_new_coverage as name{labels} value strings
+func newCodeSeries(t *testing.T, pe *PrometheusExporter) []string {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(pe)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, family := range families {
+		if family.GetName() != "sonar_new_coverage" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var pairs []string
+			for _, pair := range m.GetLabel() {
+				pairs = append(pairs, fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue()))
+			}
+			res = append(res, fmt.Sprintf("%s{%s} %v", family.GetName(), strings.Join(pairs, ","), m.GetGauge().GetValue()))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// TestNewCodeSeriesAcrossVersions reports the same new code measure as returned by Sonar prior to 8.0,
+// as periods arrays, and by 8.0+, as a single period, so series must not change once Sonar is upgraded
+func TestNewCodeSeriesAcrossVersions(t *testing.T) {
+	c := &sonar.Component{}
+	c.Key, c.Name, c.Qualifier = "shop", "Shop", "TRK"
+	period := sonar.Period{Mode: "previous_version", Parameter: "2.2"}
+
+	current := &sonar.Measures{Period: &period}
+	current.Component.Key = "shop"
+	measure := &sonar.Measure{Metric: "new_coverage"}
+	measure.Period.Value = "64.2"
+	current.Component.Measures = []*sonar.Measure{measure}
+	pe := benchExporter()
+	pe.Report(c, current)
+	want := newCodeSeries(t, pe)
+	if len(want) != 1 {
+		t.Fatalf("series of single period %v, want one", want)
+	}
+
+	legacyPeriod := period
+	legacyPeriod.Index = 1
+	legacy := &sonar.Measures{Periods: []*sonar.Period{&legacyPeriod, {Index: 2, Mode: "days", Parameter: "30"}}}
+	legacy.Component.Key = "shop"
+	measure = &sonar.Measure{Metric: "new_coverage"}
+	measure.Periods = append(measure.Periods,
+		struct {
+			Index int    `json:"index"`
+			Value string `json:"value"`
+		}{Index: 1, Value: "64.2"},
+		struct {
+			Index int    `json:"index"`
+			Value string `json:"value"`
+		}{Index: 2, Value: "70"})
+	legacy.Component.Measures = []*sonar.Measure{measure}
+	pe = benchExporter()
+	pe.Report(c, legacy)
+	got := newCodeSeries(t, pe)
+
+	want = append(want, `sonar_new_coverage{component="shop",period_index="2",period_mode="days",period_parameter="30"} 70`)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("series of periods arrays\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTagsToLabelsOfShuffledTags(t *testing.T) {
+	pe := benchExporter()
+	tags := []string{"team#web", "team#mobile", "team-x#a", "team_x#b", "env#prod", "component#shop", "misc", "a#b#c"}
+	// tags are converted in sorted order, so the first value after sorting wins
+	want := prometheus.Labels{"env": "prod", "team": "mobile", "team_x": "a"}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		rnd.Shuffle(len(tags), func(i, j int) { tags[i], tags[j] = tags[j], tags[i] })
+		if got := pe.tagsToLabels(tags); !reflect.DeepEqual(got, want) {
+			t.Fatalf("labels %v of tags %v, want %v", got, tags, want)
+		}
+	}
+}
+
+func TestGateTransitionsOfRemovedComponents(t *testing.T) {
+	pe := benchExporter()
+	report := func(key, gate string) {
+		c := &sonar.Component{}
+		c.Key, c.Name, c.Qualifier = key, key, "TRK"
+		m := &sonar.Measures{}
+		m.Component.Key = key
+		m.Component.Measures = []*sonar.Measure{{Metric: "alert_status", Value: gate}}
+		pe.Report(c, m)
+	}
+	transitions := func() []string {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(pe)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []string
+		for _, family := range families {
+			if family.GetName() != "sonar_quality_gate_transitions_total" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				var values []string
+				for _, pair := range m.GetLabel() {
+					values = append(values, pair.GetValue())
+				}
+				res = append(res, fmt.Sprintf("%s %v", strings.Join(values, ","), m.GetCounter().GetValue()))
+			}
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	for _, gate := range []string{"OK", "ERROR", "OK", "ERROR"} {
+		report("shop", gate)
+	}
+	for _, gate := range []string{"OK", "ERROR"} {
+		report("cart", gate)
+		report("blog", gate)
+	}
+	want := []string{"blog,OK,ERROR 1", "cart,OK,ERROR 1", "shop,ERROR,OK 1", "shop,OK,ERROR 2"}
+	if got := transitions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("transitions %v, want %v", got, want)
+	}
+
+	pe.retain(map[string]struct{}{"cart": {}, "blog": {}})
+	pe.commit(pe.newBatch(), map[string]struct{}{"cart": {}})
+	if got, want := transitions(), []string{"cart,OK,ERROR 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("transitions %v of removed components, want %v", got, want)
+	}
+}
+
+// BenchmarkReport reports measures of all components once per iteration, as a collection cycle does
+func BenchmarkReport(b *testing.B) {
+	pe := benchExporter()
+	components, measures := benchMeasures()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, c := range components {
+			pe.Report(c, measures[i])
+		}
+	}
+}
+
+// BenchmarkCollect collects series of all reported components once per iteration, as a scrape does
+func BenchmarkCollect(b *testing.B) {
+	pe := benchExporter()
+	components, measures := benchMeasures()
+	for i, c := range components {
+		pe.Report(c, measures[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ch := make(chan prometheus.Metric, 1024)
+		go func() {
+			pe.Collect(ch)
+			close(ch)
+		}()
+		for range ch {
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// failingPullRequestsMetric is a pseudo metric of pull requests failing quality gate
+var failingPullRequestsMetric = &sonar.Metric{
+	Key:         "pull_requests_failing_quality_gate",
+	Description: "Number of pull requests failing quality gate by target branch",
+}
+
+// collectPullRequests counts component's pull requests failing quality gate by target branch if enabled
+// by configuration. Failures are logged only, so measures of the component are still reported
+func (c *Collector) collectPullRequests(key string) []extraSeries {
+	if !c.optionalEnabled(optionalPullRequests) {
+		return nil
+	}
+	pulls, err := c.sonar.GetPullRequests(key)
+	if err != nil {
+		c.optionalFailed(optionalPullRequests, "Unable to collect pull requests of component "+key, err)
+		return nil
+	}
+	// every target branch is reported, so the count drops to zero once failing pull requests are fixed
+	failing := map[string]int{}
+	for _, pull := range pulls {
+		count := failing[pull.Base]
+		if pull.Status != nil && pull.Status.QualityGateStatus == "ERROR" {
+			count++
+		}
+		failing[pull.Base] = count
+	}
+	extras := make([]extraSeries, 0, len(failing))
+	for target, count := range failing {
+		extras = append(extras, extraSeries{
+			metric: failingPullRequestsMetric,
+			labels: map[string]string{"target_branch": target},
+			value:  float64(count),
+		})
+	}
+	return extras
+}
@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errQuietHours is returned by on-demand requests of Sonar API during quiet hours
+var errQuietHours = errors.New("collection is paused during quiet hours")
+
+// QuietHours is a daily window collection is paused during, e.g. Sonar's nightly housekeeping.
+// Cached measures are exported meanwhile
+type QuietHours struct {
+	// Start and End are wall clock times of the window as offsets from midnight. Window spans midnight
+	// if End is before Start
+	Start, End time.Duration
+	// Weekdays are days the window starts on. Empty means every day
+	Weekdays []time.Weekday
+	// Location is a time zone of the window
+	Location *time.Location
+}
+
+// weekdays are days of week by their abbreviations
+var weekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+// ParseQuietHours parses window in form of 02:00-03:00 in time zone of the location. Window may be preceded
+// by comma separated days it starts on, e.g. Sat,Sun 02:00-06:00
+func ParseQuietHours(s string, loc *time.Location) (*QuietHours, error) {
+	invalid := fmt.Errorf("invalid quiet hours %q, [Mon,...,Sun] HH:MM-HH:MM expected", s)
+	q := &QuietHours{Location: loc}
+	window := strings.TrimSpace(s)
+	if i := strings.IndexAny(window, " \t"); i > 0 && !strings.ContainsAny(window[:i], ":-") {
+		for _, day := range strings.Split(window[:i], ",") {
+			weekday, found := weekdays[strings.ToLower(day)]
+			if !found {
+				return nil, invalid
+			}
+			q.Weekdays = append(q.Weekdays, weekday)
+		}
+		window = window[i:]
+	}
+
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+	for i, bound := range []*time.Duration{&q.Start, &q.End} {
+		t, err := time.Parse("15:04", strings.TrimSpace(parts[i]))
+		if err != nil {
+			return nil, invalid
+		}
+		*bound = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if q.Start == q.End {
+		return nil, fmt.Errorf("invalid quiet hours %q, window is empty", s)
+	}
+	return q, nil
+}
+
+// startsOn reports whether the window starts on the day
+func (q *QuietHours) startsOn(day time.Weekday) bool {
+	if len(q.Weekdays) == 0 {
+		return true
+	}
+	for _, weekday := range q.Weekdays {
+		if weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// remaining returns time left until the window ends. Zero if the time is outside of the window
+func (q *QuietHours) remaining(t time.Time) time.Duration {
+	t = t.In(q.Location)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	var inside bool
+	switch {
+	case q.Start < q.End:
+		inside = q.Start <= clock && clock < q.End && q.startsOn(t.Weekday())
+	case clock >= q.Start:
+		inside = q.startsOn(t.Weekday())
+	case clock < q.End:
+		// window spanning midnight started the day before
+		inside = q.startsOn(t.AddDate(0, 0, -1).Weekday())
+	}
+	if !inside {
+		return 0
+	}
+	left := q.End - clock
+	if left <= 0 {
+		left += 24 * time.Hour
+	}
+	return left
+}
+
+// quiet reports whether collection is paused by quiet hours and returns time left until they end
+func (c *Collector) quiet() (bool, time.Duration) {
+	if c.cfg.QuietHours == nil {
+		return false, 0
+	}
+	left := c.cfg.QuietHours.remaining(time.Now())
+	return left > 0, left
+}
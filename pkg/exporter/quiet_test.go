@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		spec       string
+		start, end time.Duration
+		weekdays   []time.Weekday
+		invalid    bool
+	}{
+		{spec: "02:00-03:00", start: 2 * time.Hour, end: 3 * time.Hour},
+		{spec: " 23:30 - 01:15 ", start: 23*time.Hour + 30*time.Minute, end: time.Hour + 15*time.Minute},
+		{spec: "Sat,Sun 02:00-06:00", start: 2 * time.Hour, end: 6 * time.Hour, weekdays: []time.Weekday{time.Saturday, time.Sunday}},
+		{spec: "fri 22:00-02:00", start: 22 * time.Hour, end: 2 * time.Hour, weekdays: []time.Weekday{time.Friday}},
+		{spec: "", invalid: true},
+		{spec: "02:00", invalid: true},
+		{spec: "02:00-03:00-04:00", invalid: true},
+		{spec: "2am-3am", invalid: true},
+		{spec: "24:00-01:00", invalid: true},
+		{spec: "02:00-02:00", invalid: true},
+		{spec: "Weekend 02:00-03:00", invalid: true},
+		{spec: "Sat, Sun 02:00-03:00", invalid: true},
+		{spec: "Sat,Sun", invalid: true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuietHours(tt.spec, time.UTC)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("%q parsed as %+v, want error", tt.spec, q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+			continue
+		}
+		if q.Start != tt.start || q.End != tt.end || len(q.Weekdays) != len(tt.weekdays) {
+			t.Errorf("%q parsed as %+v", tt.spec, q)
+			continue
+		}
+		for i, day := range tt.weekdays {
+			if q.Weekdays[i] != day {
+				t.Errorf("%q parsed with weekdays %v, want %v", tt.spec, q.Weekdays, tt.weekdays)
+			}
+		}
+	}
+}
+
+func TestQuietHoursRemaining(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	// at returns time of 2026-10-<day> in UTC. 17th is Saturday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		spec string
+		loc  *time.Location
+		at   time.Time
+		want time.Duration
+	}{
+		{name: "inside", spec: "02:00-03:00", at: at(15, 2, 20), want: 40 * time.Minute},
+		{name: "start boundary", spec: "02:00-03:00", at: at(15, 2, 0), want: time.Hour},
+		{name: "end boundary", spec: "02:00-03:00", at: at(15, 3, 0)},
+		{name: "before", spec: "02:00-03:00", at: at(15, 1, 59)},
+		{name: "time zone", spec: "02:00-03:00", loc: berlin, at: at(15, 1, 30), want: 30 * time.Minute},
+		{name: "outside in time zone", spec: "02:00-03:00", loc: berlin, at: at(15, 2, 30)},
+		{name: "before midnight", spec: "23:00-01:00", at: at(15, 23, 30), want: 90 * time.Minute},
+		{name: "after midnight", spec: "23:00-01:00", at: at(16, 0, 30), want: 30 * time.Minute},
+		{name: "end boundary after midnight", spec: "23:00-01:00", at: at(16, 1, 0)},
+		{name: "between spanning window", spec: "23:00-01:00", at: at(16, 12, 0)},
+		{name: "listed day", spec: "Sat,Sun 02:00-06:00", at: at(17, 5, 0), want: time.Hour},
+		{name: "another listed day", spec: "Sat,Sun 02:00-06:00", at: at(18, 2, 0), want: 4 * time.Hour},
+		{name: "unlisted day", spec: "Sat,Sun 02:00-06:00", at: at(16, 5, 0)},
+		{name: "started on listed day", spec: "Sun 22:00-02:00", at: at(19, 1, 0), want: time.Hour},
+		{name: "started on unlisted day", spec: "Sun 22:00-02:00", at: at(18, 1, 0)},
+		{name: "listed day before midnight", spec: "Sun 22:00-02:00", at: at(18, 23, 0), want: 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		loc := tt.loc
+		if loc == nil {
+			loc = time.UTC
+		}
+		q, err := ParseQuietHours(tt.spec, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.remaining(tt.at); got != tt.want {
+			t.Errorf("%s: %s left of %s at %s, want %s", tt.name, got, tt.spec, tt.at.Format(time.RFC1123), tt.want)
+		}
+	}
+}
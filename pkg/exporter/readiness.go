@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ReadyPath is a path of readiness endpoint
+const ReadyPath = "/ready"
+
+// successRatio returns share of components collected successfully during the cycle.
+// Cycle without components is considered successful
+func (s *cycleStats) successRatio() float64 {
+	total := s.scraped + s.skipped + s.failed
+	if total == 0 {
+		return 1
+	}
+	return float64(s.scraped+s.skipped) / float64(total)
+}
+
+// updateReadiness records success ratio of the finished cycle
+func (c *Collector) updateReadiness(ratio float64) {
+	c.self.successRatio.Set(ratio)
+
+	c.readinessMut.Lock()
+	defer c.readinessMut.Unlock()
+	c.successRatio = ratio
+	c.collected = true
+}
+
+// ready reports whether a collection cycle has finished and it collected at least MinSuccessRatio of components
+func (c *Collector) ready() (bool, float64) {
+	c.readinessMut.RLock()
+	defer c.readinessMut.RUnlock()
+	return c.collected && c.successRatio >= c.cfg.MinSuccessRatio, c.successRatio
+}
+
+// ReadyHandler serves readiness probes. Responds with 503 until a collection cycle collects
+// at least MinSuccessRatio of components, so load balancers route scrapes to healthy instances only
+func (c *Collector) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		ready, ratio := c.ready()
+		if !ready {
+			http.Error(w, fmt.Sprintf("not ready, success ratio of the last cycle is %.2f", ratio), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "ready, success ratio of the last cycle is %.2f\n", ratio)
+	})
+}
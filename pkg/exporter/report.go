@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportDatePlaceholder in report path is replaced with the date of the cycle, so every day gets its own report
+const reportDatePlaceholder = "{date}"
+
+// reportMetrics are keys of metrics reported as CSV columns along with project key and quality gate status
+var reportMetrics = []string{"coverage", "bugs", "vulnerabilities", "code_smells", "duplicated_lines_density", "ncloc"}
+
+// keptMetric reports whether overall value of the metric is kept in snapshots for rollups and reports
+func keptMetric(key string) bool {
+	if _, found := rollupMetrics[key]; found {
+		return true
+	}
+	for _, m := range reportMetrics {
+		if m == key {
+			return true
+		}
+	}
+	return false
+}
+
+// reportName returns name of report uploaded at the time, so every day gets its own report
+func reportName(now time.Time) string {
+	return "sonar-" + now.Format("2006-01-02") + ".csv"
+}
+
+// writeReport writes CSV report to the file and uploads it if enabled. Failures are logged only
+func (c *Collector) writeReport(now time.Time) {
+	content, err := c.exporter.csvReport()
+	if err != nil {
+		log.Printf("Unable to write report: %v", err)
+		return
+	}
+	if c.cfg.ReportPath != "" {
+		path := strings.ReplaceAll(c.cfg.ReportPath, reportDatePlaceholder, now.Format("2006-01-02"))
+		if err := writeFileAtomically(path, content); err != nil {
+			log.Printf("Unable to write report: %v", err)
+		}
+	}
+	if c.cfg.ReportUpload != nil {
+		if err := c.cfg.ReportUpload(reportName(now), content); err != nil {
+			log.Printf("Unable to upload report: %v", err)
+		}
+	}
+}
+
+// csvReport builds CSV report of measures of exported components, one row per component
+func (pe *PrometheusExporter) csvReport() ([]byte, error) {
+	header := append([]string{"project", "quality_gate"}, reportMetrics...)
+	header = append(header, "analyzed", "collected")
+
+	pe.mut.RLock()
+	rows := make([][]string, 0, len(pe.components))
+	for key, snapshot := range pe.components {
+		if snapshot.labels.dropped {
+			continue
+		}
+		row := make([]string, 0, len(header))
+		row = append(row, key, snapshot.gate)
+		for _, m := range reportMetrics {
+			if val, found := snapshot.values[m]; found {
+				row = append(row, strconv.FormatFloat(val, 'f', -1, 64))
+			} else {
+				row = append(row, "")
+			}
+		}
+		analyzed := ""
+		if !snapshot.analyzed.IsZero() {
+			analyzed = snapshot.analyzed.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, append(row, analyzed, snapshot.collected.UTC().Format(time.RFC3339)))
+	}
+	pe.mut.RUnlock()
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("unable to build report: %w", err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("unable to build report: %w", err)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const rollupSubsystem = "org"
+
+// rollupMetrics are keys of metrics whose values are kept for organization-wide rollups
+var rollupMetrics = map[string]struct{}{"ncloc": {}, "coverage": {}}
+
+var (
+	orgProjectsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, rollupSubsystem, "projects"),
+		"Number of exported projects", nil, nil)
+	orgNclocDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, rollupSubsystem, "ncloc"),
+		"Total number of lines of code of exported projects", nil, nil)
+	orgGateDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, rollupSubsystem, "projects_by_quality_gate"),
+		"Number of exported projects by quality gate status", []string{"status"}, nil)
+	orgCoverageDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, rollupSubsystem, "coverage_mean"),
+		"Mean coverage of exported projects having coverage", nil, nil)
+	orgLanguageDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, rollupSubsystem, "projects_by_language"),
+		"Number of exported projects having code in the language", []string{"language"}, nil)
+)
+
+// Rollup exports organization-wide totals computed from measures already collected by the exporter,
+// so dashboards do not aggregate thousands of per-project series
+type Rollup struct {
+	exporter *PrometheusExporter
+}
+
+// NewRollup creates collector of organization-wide totals of exporter's measures
+func NewRollup(exp *PrometheusExporter) *Rollup {
+	return &Rollup{exporter: exp}
+}
+
+// Describe implements prometheus.Collector
+func (r *Rollup) Describe(ch chan<- *prometheus.Desc) {
+	ch <- orgProjectsDesc
+	ch <- orgNclocDesc
+	ch <- orgGateDesc
+	ch <- orgCoverageDesc
+	ch <- orgLanguageDesc
+}
+
+// Collect implements prometheus.Collector
+func (r *Rollup) Collect(ch chan<- prometheus.Metric) {
+	pe := r.exporter
+	pe.mut.RLock()
+	var projects, ncloc, coverage, covered float64
+	gates, languages := map[string]float64{}, map[string]float64{}
+	now := time.Now()
+	for _, snapshot := range pe.components {
+		if snapshot.labels.dropped || snapshot.merged || pe.maxStaleness > 0 && now.Sub(snapshot.collected) > pe.maxStaleness {
+			continue
+		}
+		projects++
+		ncloc += snapshot.values["ncloc"]
+		if val, found := snapshot.values["coverage"]; found {
+			coverage += val
+			covered++
+		}
+		if snapshot.gate != "" {
+			gates[snapshot.gate]++
+		}
+		for language := range snapshot.languages {
+			languages[language]++
+		}
+	}
+	pe.mut.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(orgProjectsDesc, prometheus.GaugeValue, projects)
+	ch <- prometheus.MustNewConstMetric(orgNclocDesc, prometheus.GaugeValue, ncloc)
+	for gate, count := range gates {
+		ch <- prometheus.MustNewConstMetric(orgGateDesc, prometheus.GaugeValue, count, gate)
+	}
+	for language, count := range languages {
+		ch <- prometheus.MustNewConstMetric(orgLanguageDesc, prometheus.GaugeValue, count, language)
+	}
+	if covered > 0 {
+		ch <- prometheus.MustNewConstMetric(orgCoverageDesc, prometheus.GaugeValue, coverage/covered)
+	}
+}
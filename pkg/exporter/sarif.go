@@ -0,0 +1,225 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// SARIFPath is a path prefix of endpoint serving open issues of a project in SARIF format
+const SARIFPath = "/api/v1/sarif/"
+
+// sarifVersion is a version of SARIF format and sarifSchema is its JSON schema
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// errNotCollected is returned for projects the exporter does not collect, e.g. opted out or of another shard
+var errNotCollected = errors.New("project is not collected by the exporter")
+
+// sarifLevels are SARIF levels of Sonar severities. Unknown severities are reported as warnings
+var sarifLevels = map[string]string{
+	"BLOCKER":  "error",
+	"CRITICAL": "error",
+	"MAJOR":    "warning",
+	"MINOR":    "note",
+	"INFO":     "note",
+}
+
+// issuesSnapshot is a set of open issues of a project as of its analysis
+type issuesSnapshot struct {
+	analyzed time.Time
+	issues   []*sonar.Issue
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	EndLine     int `json:"endLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// openIssues returns open issues of the project. Issues are fetched once per analysis of the project,
+// so repeated requests cost no API calls until the project is analyzed again
+func (c *Collector) openIssues(key string) ([]*sonar.Issue, error) {
+	c.componentsMut.Lock()
+	component, found := c.components[key]
+	c.componentsMut.Unlock()
+	if !found || c.optedOut(component) {
+		return nil, errNotCollected
+	}
+	analyzed := time.Time(component.AnalysisDate)
+
+	c.snapshotsMut.Lock()
+	snapshot, found := c.snapshots[key]
+	c.snapshotsMut.Unlock()
+	if found && snapshot.analyzed.Equal(analyzed) {
+		return snapshot.issues, nil
+	}
+	if quiet, _ := c.quiet(); quiet {
+		return nil, errQuietHours
+	}
+
+	issues, err := c.sonar.GetOpenIssues(key)
+	if err != nil {
+		return nil, err
+	}
+	c.snapshotsMut.Lock()
+	c.snapshots[key] = &issuesSnapshot{analyzed: analyzed, issues: issues}
+	c.snapshotsMut.Unlock()
+	return issues, nil
+}
+
+// toSARIF converts issues of the project to SARIF log of a single run
+func toSARIF(key, sonarURL string, issues []*sonar.Issue) *sarifLog {
+	rules := map[string]struct{}{}
+	results := make([]sarifResult, 0, len(issues))
+	for _, issue := range issues {
+		rules[issue.Rule] = struct{}{}
+		level, found := sarifLevels[issue.Severity]
+		if !found {
+			level = "warning"
+		}
+		result := sarifResult{
+			RuleID:              issue.Rule,
+			Level:               level,
+			Message:             sarifMessage{Text: issue.Message},
+			PartialFingerprints: map[string]string{"sonarIssueKey": issue.Key},
+			Properties:          map[string]string{"type": issue.Type, "severity": issue.Severity},
+		}
+		// issues of the project itself have no file
+		if path := strings.TrimPrefix(issue.Component, key+":"); path != issue.Component {
+			result.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: path},
+				Region:           sarifRegionOf(issue),
+			}}}
+		}
+		results = append(results, result)
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	driver := sarifDriver{Name: "SonarQube", InformationURI: sonarURL, Rules: make([]sarifRule, 0, len(ids))}
+	for _, id := range ids {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id})
+	}
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+// sarifRegionOf returns region of the issue within the file. Nil for issues of the whole file.
+// Sonar offsets are zero based while SARIF columns start from one
+func sarifRegionOf(issue *sonar.Issue) *sarifRegion {
+	if r := issue.TextRange; r != nil {
+		return &sarifRegion{
+			StartLine:   r.StartLine,
+			EndLine:     r.EndLine,
+			StartColumn: r.StartOffset + 1,
+			EndColumn:   r.EndOffset + 1,
+		}
+	}
+	if issue.Line > 0 {
+		return &sarifRegion{StartLine: issue.Line}
+	}
+	return nil
+}
+
+// SARIFHandler serves GET /api/v1/sarif/{projectKey} requests
+func (c *Collector) SARIFHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		if rq.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(rq.URL.Path, SARIFPath)
+		if key == "" || strings.Contains(key, "/") {
+			http.Error(w, "project key is required", http.StatusBadRequest)
+			return
+		}
+
+		issues, err := c.openIssues(key)
+		switch {
+		case errors.Is(err, errNotCollected):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, errQuietHours):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			log.Printf("Unable to get issues of component %s: %v", key, err)
+			http.Error(w, fmt.Sprintf("unable to get issues: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/sarif+json")
+		if err := json.NewEncoder(w).Encode(toSARIF(key, c.sonar.URL(), issues)); err != nil {
+			log.Print(err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// ErrorPolicy defines collector's behavior in case of failed collection cycles
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyContinue logs errors and keeps collecting with usual interval
+	ErrorPolicyContinue ErrorPolicy = "continue"
+	// ErrorPolicyExit stops collection once max consecutive failures reached
+	ErrorPolicyExit ErrorPolicy = "exit"
+	// ErrorPolicyBackoff increases interval between failed cycles
+	// and stops collection once max consecutive failures reached (if configured)
+	ErrorPolicyBackoff ErrorPolicy = "backoff"
+)
+
+// maxBackoffShift limits backoff interval to 16 collection intervals
+const maxBackoffShift = 4
+
+// ParseErrorPolicy converts string to error policy
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch p := ErrorPolicy(s); p {
+	case ErrorPolicyContinue, ErrorPolicyExit, ErrorPolicyBackoff:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown error policy: %s", s)
+	}
+}
+
+// cycle executes collection cycle and applies error policy.
+// Returns delay before next cycle or error if collection must be stopped
+func (c *Collector) cycle() (time.Duration, error) {
+	if quiet, left := c.quiet(); quiet {
+		log.Printf("Quiet hours, collection is paused for %s", left.Round(time.Second))
+		c.self.quietHours.Set(1)
+		return left, nil
+	}
+	c.self.quietHours.Set(0)
+
+	if c.cfg.Leader != nil {
+		if !c.cfg.Leader() {
+			log.Println("Not a leader, skipping collection cycle")
+			c.self.leader.Set(0)
+			// followers expose no measures to avoid duplicated series
+			c.exporter.retain(nil)
+			c.retainUp(nil)
+			// followers are ready to take over, so they are kept in rotation instead of reported unhealthy
+			c.updateReadiness(1)
+			return c.cfg.ScrapeTimeout, nil
+		}
+		c.self.leader.Set(1)
+	}
+
+	// collection is resumed once Sonar is up again
+	if c.maintenance > 0 && c.underMaintenance() {
+		return c.maintenanceDelay(), nil
+	}
+
+	err := c.collect()
+	if err == nil {
+		if c.notified {
+			c.notify("Collection recovered after %d failed cycles", c.failures)
+			c.notified = false
+		}
+		c.failures = 0
+		c.self.consecutiveFailures.Set(0)
+		return c.interval, nil
+	}
+
+	// requests fail during upgrades and restarts of Sonar, which is not a failure of collection
+	if c.underMaintenance() {
+		return c.maintenanceDelay(), nil
+	}
+
+	c.failures++
+	c.self.consecutiveFailures.Set(float64(c.failures))
+	log.Printf("Collection cycle failed (%d in a row): %v", c.failures, err)
+	c.notifyFailure(err)
+
+	switch c.cfg.OnError {
+	case ErrorPolicyExit:
+		if c.failures >= c.cfg.MaxFailures {
+			return 0, c.stop(err)
+		}
+	case ErrorPolicyBackoff:
+		if c.cfg.MaxFailures > 0 && c.failures >= c.cfg.MaxFailures {
+			return 0, c.stop(err)
+		}
+		shift := c.failures
+		if shift > maxBackoffShift {
+			shift = maxBackoffShift
+		}
+		return c.interval << shift, nil
+	case ErrorPolicyContinue:
+	}
+	return c.interval, nil
+}
+
+// stop notifies that collection is stopped by error policy and returns the cause
+func (c *Collector) stop(err error) error {
+	err = fmt.Errorf("%d collection cycles failed in a row: %w", c.failures, err)
+	c.notify("Collection stopped: %v", err)
+	return err
+}
+
+// notifyFailure notifies about failing collection once consecutive failures reach the threshold.
+// Authentication failures are notified immediately since they do not recover without intervention
+func (c *Collector) notifyFailure(err error) {
+	if c.notified {
+		return
+	}
+	switch {
+	case errors.Is(err, sonar.ErrUnauthorized):
+		c.notify("Sonar rejects exporter's credentials: %v", err)
+	case c.failures >= c.cfg.NotifyThreshold:
+		c.notify("%d collection cycles failed in a row: %v", c.failures, err)
+	default:
+		return
+	}
+	c.notified = true
+}
+
+// notify sends notification if notifications are enabled
+func (c *Collector) notify(format string, args ...interface{}) {
+	if c.cfg.Notify != nil {
+		c.cfg.Notify(fmt.Sprintf(format, args...))
+	}
+}
+
+// schedule executes callback until done is closed or callback returns an error.
+// Callback returns delay before its next execution
+func schedule(done <-chan struct{}, initialDelay time.Duration, callback func() (time.Duration, error)) error {
+	attemptTimer := time.After(initialDelay)
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-attemptTimer:
+			timeout, err := callback()
+			if err != nil {
+				return err
+			}
+			attemptTimer = time.After(timeout)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// SonarAPI is a part of Sonar Web API consumed by Collector. Implemented by *sonar.Client,
+// see sonartest.MockAPI for tests of code embedding the collector
+type SonarAPI interface {
+	GetComponents() ([]*sonar.ComponentInfo, error)
+	SearchProjects(filter, sort string) ([]*sonar.ComponentInfo, error)
+	GetPortfolios() ([]*sonar.ComponentInfo, error)
+	GetComponent(key string) (*sonar.Component, error)
+	GetMetrics() ([]*sonar.Metric, error)
+	GetMeasures(key string, metrics []string) (*sonar.Measures, error)
+	SearchIssues(query url.Values) (*sonar.Issues, error)
+	GetOpenIssues(key string) ([]*sonar.Issue, error)
+	GetUserTokens() ([]*sonar.UserToken, error)
+	GetCustomMeasures(key string) ([]*sonar.CustomMeasure, error)
+	GetProjectLinks(key string) ([]*sonar.ProjectLink, error)
+	GetPullRequests(key string) ([]*sonar.PullRequest, error)
+	GetProjectStatus(key string) (*sonar.ProjectStatus, error)
+	GetProjectQualityGate(key string) (*sonar.QualityGate, error)
+	GetServerInfo() (*sonar.ServerInfo, error)
+	GetSystemStatus() (*sonar.SystemStatus, error)
+
+	// Requests returns total number of executed API requests
+	Requests() uint64
+	// Failures returns total number of requests failed due to server or network errors
+	Failures() uint64
+	// Latency returns total duration of executed requests
+	Latency() time.Duration
+	// SetBudget limits number of requests executed from now on. Negative budget removes the limit
+	SetBudget(budget int64)
+	// URLs returns base URLs of Sonar, the primary one first
+	URLs() []string
+	// URL returns base URL of Sonar in use
+	URL() string
+}
+
+var _ SonarAPI = (*sonar.Client)(nil)
@@ -0,0 +1,36 @@
+package exporter
+
+import (
+	"log"
+	"time"
+)
+
+// checkTokens exports time left before expiration of user tokens
+// and warns if some of them expire soon
+func (c *Collector) checkTokens() {
+	tokens, err := c.sonar.GetUserTokens()
+	if err != nil {
+		c.optionalFailed(optionalTokens, "Unable to check user tokens", err)
+		return
+	}
+
+	c.self.tokenExpiresIn.Reset()
+	found := false
+	for _, token := range tokens {
+		if c.cfg.TokenName != "" && token.Name != c.cfg.TokenName {
+			continue
+		}
+		found = true
+		if token.ExpirationDate.IsZero() {
+			continue
+		}
+		expiresIn := time.Until(token.ExpirationDate.Time())
+		c.self.tokenExpiresIn.WithLabelValues(token.Name).Set(expiresIn.Seconds())
+		if expiresIn < c.cfg.TokenExpiryWarning {
+			log.Printf("WARNING: Sonar token %s expires in %s", token.Name, expiresIn.Round(time.Minute))
+		}
+	}
+	if c.cfg.TokenName != "" && !found {
+		log.Printf("Sonar token %s not found", c.cfg.TokenName)
+	}
+}
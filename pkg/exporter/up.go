@@ -0,0 +1,37 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// componentUpDesc describes whether the last collection of components succeeded
+var componentUpDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "component", "up"),
+	"Whether the last collection of the component succeeded", []string{componentLabel}, nil)
+
+// setUp records result of component collection
+func (c *Collector) setUp(key string, up bool) {
+	c.upMut.Lock()
+	defer c.upMut.Unlock()
+	c.up[key] = up
+}
+
+// retainUp forgets results of components other than provided ones, e.g. deleted or opted out.
+// Components of other slices keep results of their last collection
+func (c *Collector) retainUp(keys map[string]struct{}) {
+	c.upMut.Lock()
+	defer c.upMut.Unlock()
+	for key := range c.up {
+		if _, found := keys[key]; !found {
+			delete(c.up, key)
+		}
+	}
+}
+
+// collectUp sends results of the last collection of components
+func (c *Collector) collectUp(ch chan<- prometheus.Metric) {
+	c.upMut.Lock()
+	defer c.upMut.Unlock()
+	for key, up := range c.up {
+		ch <- prometheus.MustNewConstMetric(componentUpDesc, prometheus.GaugeValue, boolValue(up), key)
+	}
+}
@@ -0,0 +1,730 @@
+package sonar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Client is a SonarQube Web API client
+type Client struct {
+	// requests is a number of executed requests. Accessed atomically
+	requests uint64
+	// failures is a number of requests failed due to server or network errors. Accessed atomically
+	failures uint64
+	// latency is a total duration of executed requests in nanoseconds. Accessed atomically
+	latency int64
+	// limited is 1 if number of requests is limited by budget. Accessed atomically
+	limited int32
+	// budget is a number of requests left before ErrBudgetExhausted is returned. Accessed atomically
+	budget int64
+
+	c         *http.Client
+	userAgent string
+	// headers are static headers sent with every request
+	headers http.Header
+	// limiter limits rate of requests. Nil means no limit
+	limiter *RateLimiter
+	// retries is a max number of retries of requests failed due to transient errors, backoff is a delay before the first one
+	retries int
+	backoff time.Duration
+	// basePath is a context path Sonar is served under, e.g. /sonar. Empty if served at root
+	basePath string
+
+	// urls are base URLs of Sonar. The first one is primary, the rest are failover ones
+	urls []string
+	// active is an index of URL in use. Accessed atomically
+	active int32
+
+	credentialsMut sync.RWMutex
+	user           string
+	password       string
+	// reload returns actual credentials once Sonar rejects current ones. Nil disables reload
+	reload func() (user, password string, err error)
+
+	// cache keeps list responses by URL to revalidate them with conditional requests
+	cacheMut sync.Mutex
+	cache    map[string]*cachedResponse
+}
+
+// cachedResponse is a response body along with its validators
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// DefaultUserAgent is sent with API requests unless overridden with WithUserAgent
+const DefaultUserAgent = "sonarqube-prometheus-exporter"
+
+// NewClient creates new SonarQube API client which uses basic auth. Requests are limited by DefaultTimeout
+// and are not retried unless configured otherwise with options
+func NewClient(url, user, password string, opts ...Option) *Client {
+	s := &Client{
+		user:      user,
+		password:  password,
+		userAgent: DefaultUserAgent,
+		c:         &http.Client{Timeout: DefaultTimeout},
+		cache:     map[string]*cachedResponse{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.urls = []string{s.baseURL(url)}
+	return s
+}
+
+// SetCredentialsReload makes client obtain new credentials with reload and retry request once
+// Sonar rejects current ones, e.g. after token rotation
+func (s *Client) SetCredentialsReload(reload func() (user, password string, err error)) {
+	s.reload = reload
+}
+
+// SetHeaders sets static headers sent with every API request, e.g. API keys of gateways Sonar is fronted with
+func (s *Client) SetHeaders(headers http.Header) {
+	s.headers = headers
+}
+
+// SetRateLimiter limits rate of API requests prioritizing requests measures are collected with
+func (s *Client) SetRateLimiter(limiter *RateLimiter) {
+	s.limiter = limiter
+}
+
+// pageSize is a max page size supported by Sonar search APIs
+const pageSize = 500
+
+// maxIssues is a max number of issues Sonar returns for a single search
+const maxIssues = 10000
+
+// GetComponents returns all projects
+func (s *Client) GetComponents() ([]*ComponentInfo, error) {
+	return s.GetComponentsContext(context.Background())
+}
+
+// GetComponentsContext is GetComponents canceled along with ctx
+func (s *Client) GetComponentsContext(ctx context.Context) ([]*ComponentInfo, error) {
+	return s.searchComponents(ctx, "TRK")
+}
+
+// SearchProjects returns projects matching filter of api/components/search_projects, e.g. alert_status = ERROR,
+// sorted by field, e.g. ncloc. Field prefixed with - sorts in descending order. Empty filter matches all projects
+func (s *Client) SearchProjects(filter, sort string) ([]*ComponentInfo, error) {
+	return s.SearchProjectsContext(context.Background(), filter, sort)
+}
+
+// SearchProjectsContext is SearchProjects canceled along with ctx
+func (s *Client) SearchProjectsContext(ctx context.Context, filter, sort string) ([]*ComponentInfo, error) {
+	q := url.Values{}
+	if filter != "" {
+		q.Set("filter", filter)
+	}
+	if sort != "" {
+		q.Set("s", strings.TrimPrefix(sort, "-"))
+		q.Set("asc", strconv.FormatBool(!strings.HasPrefix(sort, "-")))
+	}
+	q.Set("ps", strconv.Itoa(pageSize))
+
+	var components []*ComponentInfo
+	for page := 1; ; page++ {
+		q.Set("p", strconv.Itoa(page))
+		var c Components
+		if err := s.executeCachedGet(ctx, "/api/components/search_projects?"+q.Encode(), &c); err != nil {
+			return nil, err
+		}
+		components = append(components, c.Components...)
+
+		// response without paging is considered as a single page
+		if c.Paging == nil || len(c.Components) == 0 || c.Paging.PageIndex*c.Paging.PageSize >= c.Paging.Total {
+			return components, nil
+		}
+	}
+}
+
+// GetPortfolios returns portfolios. Supported by Enterprise edition and above
+func (s *Client) GetPortfolios() ([]*ComponentInfo, error) {
+	return s.GetPortfoliosContext(context.Background())
+}
+
+// GetPortfoliosContext is GetPortfolios canceled along with ctx
+func (s *Client) GetPortfoliosContext(ctx context.Context) ([]*ComponentInfo, error) {
+	return s.searchComponents(ctx, "VW")
+}
+
+// searchComponents returns all components of the qualifier
+func (s *Client) searchComponents(ctx context.Context, qualifier string) ([]*ComponentInfo, error) {
+	var components []*ComponentInfo
+	for page := 1; ; page++ {
+		var c Components
+		err := s.executeCachedGet(ctx, fmt.Sprintf("/api/components/search?qualifiers=%s&p=%d&ps=%d", qualifier, page, pageSize), &c)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, c.Components...)
+
+		// response without paging is considered as a single page
+		if c.Paging == nil || len(c.Components) == 0 || c.Paging.PageIndex*c.Paging.PageSize >= c.Paging.Total {
+			return components, nil
+		}
+	}
+}
+
+// GetComponent returns metadata of the component, e.g. project
+func (s *Client) GetComponent(key string) (*Component, error) {
+	return s.GetComponentContext(context.Background(), key)
+}
+
+// GetComponentContext is GetComponent canceled along with ctx
+func (s *Client) GetComponentContext(ctx context.Context, key string) (*Component, error) {
+	var c struct {
+		Component *Component `json:"component,omitempty"`
+	}
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/components/show?component=%s", url.QueryEscape(key)), &c); err != nil {
+		return nil, err
+	}
+	if c.Component == nil {
+		return nil, fmt.Errorf("%w: no component %s", ErrIncompleteResponse, key)
+	}
+	return c.Component, nil
+}
+
+// GetMetrics returns definitions of all metrics
+func (s *Client) GetMetrics() ([]*Metric, error) {
+	return s.GetMetricsContext(context.Background())
+}
+
+// GetMetricsContext is GetMetrics canceled along with ctx
+func (s *Client) GetMetricsContext(ctx context.Context) ([]*Metric, error) {
+	var metrics []*Metric
+	for page := 1; ; page++ {
+		var m Metrics
+		err := s.executeCachedGet(ctx, fmt.Sprintf("/api/metrics/search?p=%d&ps=%d", page, pageSize), &m)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m.Metrics...)
+
+		if len(m.Metrics) == 0 || m.Ps == 0 || m.P*m.Ps >= m.Total {
+			break
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("%w: no metrics", ErrIncompleteResponse)
+	}
+	return metrics, nil
+}
+
+// GetMeasures returns measures of the component along with new code period and definitions of the metrics
+func (s *Client) GetMeasures(key string, metrics []string) (*Measures, error) {
+	return s.GetMeasuresContext(context.Background(), key, metrics)
+}
+
+// GetMeasuresContext is GetMeasures canceled along with ctx
+func (s *Client) GetMeasuresContext(ctx context.Context, key string, metrics []string) (*Measures, error) {
+	var m Measures
+	err := s.executeGet(ctx, fmt.Sprintf("/api/measures/component?component=%s&metricKeys=%s&additionalFields=periods,metrics",
+		url.QueryEscape(key), strings.Join(metrics, ",")), &m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Component.Key == "" {
+		return nil, fmt.Errorf("%w: no measures of component %s", ErrIncompleteResponse, key)
+	}
+	return &m, nil
+}
+
+// SearchIssues searches issues matching query, e.g. componentKeys and resolved parameters.
+// Only the first issue is requested, so the result carries total number of issues and requested facets
+func (s *Client) SearchIssues(query url.Values) (*Issues, error) {
+	return s.SearchIssuesContext(context.Background(), query)
+}
+
+// SearchIssuesContext is SearchIssues canceled along with ctx
+func (s *Client) SearchIssuesContext(ctx context.Context, query url.Values) (*Issues, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("ps", "1")
+	var i Issues
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/issues/search?%s", q.Encode()), &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// GetOpenIssues returns unresolved issues of the project. Sonar never returns more than maxIssues
+// issues of a single search, so the rest are dropped
+func (s *Client) GetOpenIssues(key string) ([]*Issue, error) {
+	return s.GetOpenIssuesContext(context.Background(), key)
+}
+
+// GetOpenIssuesContext is GetOpenIssues canceled along with ctx
+func (s *Client) GetOpenIssuesContext(ctx context.Context, key string) ([]*Issue, error) {
+	var pages []*Issues
+	err := s.Paginate(ctx, "/api/issues/search", url.Values{"componentKeys": {key}, "resolved": {"false"}},
+		func() Page {
+			if len(pages)*pageSize >= maxIssues {
+				return nil
+			}
+			page := &Issues{}
+			pages = append(pages, page)
+			return page
+		})
+	if err != nil {
+		return nil, err
+	}
+	var issues []*Issue
+	for _, page := range pages {
+		issues = append(issues, page.Issues...)
+	}
+	return issues, nil
+}
+
+// GetUserTokens returns tokens of the authenticated user
+func (s *Client) GetUserTokens() ([]*UserToken, error) {
+	return s.GetUserTokensContext(context.Background())
+}
+
+// GetUserTokensContext is GetUserTokens canceled along with ctx
+func (s *Client) GetUserTokensContext(ctx context.Context) ([]*UserToken, error) {
+	var t UserTokens
+	if err := s.executeGet(ctx, "/api/user_tokens/search", &t); err != nil {
+		return nil, err
+	}
+	return t.UserTokens, nil
+}
+
+// GetCustomMeasures returns custom (manual) measures of the project.
+// The API is available in Sonar versions prior to 9.0 only
+func (s *Client) GetCustomMeasures(key string) ([]*CustomMeasure, error) {
+	return s.GetCustomMeasuresContext(context.Background(), key)
+}
+
+// GetCustomMeasuresContext is GetCustomMeasures canceled along with ctx
+func (s *Client) GetCustomMeasuresContext(ctx context.Context, key string) ([]*CustomMeasure, error) {
+	var measures []*CustomMeasure
+	for page := 1; ; page++ {
+		var m CustomMeasures
+		err := s.executeGet(ctx, fmt.Sprintf("/api/custom_measures/search?projectKey=%s&p=%d&ps=%d", url.QueryEscape(key), page, pageSize), &m)
+		if err != nil {
+			return nil, err
+		}
+		measures = append(measures, m.CustomMeasures...)
+
+		if len(m.CustomMeasures) == 0 || m.Ps == 0 || m.P*m.Ps >= m.Total {
+			return measures, nil
+		}
+	}
+}
+
+// GetProjectLinks returns links of the project
+func (s *Client) GetProjectLinks(key string) ([]*ProjectLink, error) {
+	return s.GetProjectLinksContext(context.Background(), key)
+}
+
+// GetProjectLinksContext is GetProjectLinks canceled along with ctx
+func (s *Client) GetProjectLinksContext(ctx context.Context, key string) ([]*ProjectLink, error) {
+	var l ProjectLinks
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/project_links/search?projectKey=%s", url.QueryEscape(key)), &l); err != nil {
+		return nil, err
+	}
+	return l.Links, nil
+}
+
+// GetPullRequests returns pull requests of the project. Supported by Developer edition and above
+func (s *Client) GetPullRequests(key string) ([]*PullRequest, error) {
+	return s.GetPullRequestsContext(context.Background(), key)
+}
+
+// GetPullRequestsContext is GetPullRequests canceled along with ctx
+func (s *Client) GetPullRequestsContext(ctx context.Context, key string) ([]*PullRequest, error) {
+	var p PullRequests
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/project_pull_requests/list?project=%s", url.QueryEscape(key)), &p); err != nil {
+		return nil, err
+	}
+	return p.PullRequests, nil
+}
+
+// GetBranches returns branches of the project. Projects of Community edition have the main branch only
+func (s *Client) GetBranches(key string) ([]*Branch, error) {
+	return s.GetBranchesContext(context.Background(), key)
+}
+
+// GetBranchesContext is GetBranches canceled along with ctx
+func (s *Client) GetBranchesContext(ctx context.Context, key string) ([]*Branch, error) {
+	var b Branches
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/project_branches/list?project=%s", url.QueryEscape(key)), &b); err != nil {
+		return nil, err
+	}
+	return b.Branches, nil
+}
+
+// GetProjectStatus returns quality gate status of the project along with its conditions
+func (s *Client) GetProjectStatus(key string) (*ProjectStatus, error) {
+	return s.GetProjectStatusContext(context.Background(), key)
+}
+
+// GetProjectStatusContext is GetProjectStatus canceled along with ctx
+func (s *Client) GetProjectStatusContext(ctx context.Context, key string) (*ProjectStatus, error) {
+	var p struct {
+		ProjectStatus *ProjectStatus `json:"projectStatus"`
+	}
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/qualitygates/project_status?projectKey=%s", url.QueryEscape(key)), &p); err != nil {
+		return nil, err
+	}
+	if p.ProjectStatus == nil {
+		return nil, fmt.Errorf("%w: no quality gate status of project %s", ErrIncompleteResponse, key)
+	}
+	return p.ProjectStatus, nil
+}
+
+// GetProjectQualityGate returns quality gate assigned to the project
+func (s *Client) GetProjectQualityGate(key string) (*QualityGate, error) {
+	return s.GetProjectQualityGateContext(context.Background(), key)
+}
+
+// GetProjectQualityGateContext is GetProjectQualityGate canceled along with ctx
+func (s *Client) GetProjectQualityGateContext(ctx context.Context, key string) (*QualityGate, error) {
+	var g struct {
+		QualityGate *QualityGate `json:"qualityGate"`
+	}
+	if err := s.executeGet(ctx, fmt.Sprintf("/api/qualitygates/get_by_project?project=%s", url.QueryEscape(key)), &g); err != nil {
+		return nil, err
+	}
+	if g.QualityGate == nil {
+		return nil, fmt.Errorf("%w: no quality gate of project %s", ErrIncompleteResponse, key)
+	}
+	return g.QualityGate, nil
+}
+
+// GetServerInfo returns version and edition of the server
+func (s *Client) GetServerInfo() (*ServerInfo, error) {
+	return s.GetServerInfoContext(context.Background())
+}
+
+// GetServerInfoContext is GetServerInfo canceled along with ctx
+func (s *Client) GetServerInfoContext(ctx context.Context) (*ServerInfo, error) {
+	var i ServerInfo
+	if err := s.executeGet(ctx, "/api/navigation/global", &i); err != nil {
+		return nil, err
+	}
+	if i.Version == "" {
+		return nil, fmt.Errorf("%w: no server version", ErrIncompleteResponse)
+	}
+	return &i, nil
+}
+
+// GetSystemStatus returns status of Sonar server. Available without authentication
+func (s *Client) GetSystemStatus() (*SystemStatus, error) {
+	return s.GetSystemStatusContext(context.Background())
+}
+
+// GetSystemStatusContext returns status of Sonar server, see GetSystemStatus
+func (s *Client) GetSystemStatusContext(ctx context.Context) (*SystemStatus, error) {
+	var st SystemStatus
+	if err := s.executeGet(ctx, "/api/system/status", &st); err != nil {
+		return nil, err
+	}
+	if st.Status == "" {
+		return nil, fmt.Errorf("%w: no server status", ErrIncompleteResponse)
+	}
+	return &st, nil
+}
+
+// Page is a page of search API response, e.g. Components or Issues
+type Page interface {
+	// PageInfo returns paging of the page. Nil means the response is a single page
+	PageInfo() *Paging
+	// Len returns number of items of the page
+	Len() int
+}
+
+// Paginate requests pages of search API at path with query until all items are fetched. Every page is decoded
+// into a value returned by next, so the caller collects items of decoded pages. Nil returned by next stops pagination
+func (s *Client) Paginate(ctx context.Context, path string, query url.Values, next func() Page) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("ps", strconv.Itoa(pageSize))
+	for p := 1; ; p++ {
+		page := next()
+		if page == nil {
+			return nil
+		}
+		q.Set("p", strconv.Itoa(p))
+		if err := s.executeGet(ctx, path+"?"+q.Encode(), page); err != nil {
+			return err
+		}
+		info := page.PageInfo()
+		if info == nil || page.Len() == 0 || info.PageIndex*info.PageSize >= info.Total {
+			return nil
+		}
+	}
+}
+
+// Requests returns total number of API requests executed by the client
+func (s *Client) Requests() uint64 {
+	return atomic.LoadUint64(&s.requests)
+}
+
+// SetBudget limits number of requests executed from now on, retries included. Requests beyond the budget
+// fail with ErrBudgetExhausted. Negative budget removes the limit
+func (s *Client) SetBudget(budget int64) {
+	atomic.StoreInt64(&s.budget, budget)
+	if budget < 0 {
+		atomic.StoreInt32(&s.limited, 0)
+	} else {
+		atomic.StoreInt32(&s.limited, 1)
+	}
+}
+
+// Failures returns total number of API requests failed due to server overload, server or network errors
+func (s *Client) Failures() uint64 {
+	return atomic.LoadUint64(&s.failures)
+}
+
+// Latency returns total duration of API requests executed by the client
+func (s *Client) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.latency))
+}
+
+// observe accounts duration and result of the request started at provided time
+func (s *Client) observe(started time.Time, failed bool) {
+	atomic.AddInt64(&s.latency, int64(time.Since(started)))
+	if failed {
+		atomic.AddUint64(&s.failures, 1)
+	}
+}
+
+// SetFailoverURLs adds base URLs of Sonar requests fail over to once the URL in use is unreachable
+func (s *Client) SetFailoverURLs(urls ...string) {
+	for _, u := range urls {
+		s.urls = append(s.urls, s.baseURL(u))
+	}
+}
+
+// URLs returns base URLs of Sonar, primary one first
+func (s *Client) URLs() []string {
+	return s.urls
+}
+
+// URL returns base URL of Sonar in use
+func (s *Client) URL() string {
+	return s.urls[atomic.LoadInt32(&s.active)]
+}
+
+func (s *Client) executeGet(ctx context.Context, path string, res interface{}) error {
+	return s.execute(ctx, path, res, false)
+}
+
+// executeCachedGet executes conditional request if the response has been received before
+// and Sonar provided its ETag or Last-Modified header. Unchanged response is not downloaded again
+func (s *Client) executeCachedGet(ctx context.Context, path string, res interface{}) error {
+	return s.execute(ctx, path, res, true)
+}
+
+// execute requests path retrying transient failures according to retry policy of the client
+func (s *Client) execute(ctx context.Context, path string, res interface{}, cacheable bool) error {
+	for attempt := 0; ; attempt++ {
+		err := s.executeFailover(ctx, path, res, cacheable)
+		if err == nil || attempt >= s.retries || ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+		delay := s.backoff << attempt
+		// throttled or unavailable Sonar may tell when to come back
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 &&
+			(apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable) {
+			delay = apiErr.RetryAfter
+		}
+		log.Printf("Request %s failed, retrying in %s: %v", path, delay, err)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// executeFailover requests path of Sonar in use. Once Sonar is unreachable, the request is retried with the rest of URLs
+// and the first one responding is used from then on
+func (s *Client) executeFailover(ctx context.Context, path string, res interface{}, cacheable bool) error {
+	active := int(atomic.LoadInt32(&s.active))
+	var err error
+	for i := range s.urls {
+		next := (active + i) % len(s.urls)
+		err = s.executeAuthenticated(ctx, s.urls[next]+path, res, cacheable)
+		// requests canceled by caller are not retried with other URLs
+		if ctx.Err() == nil && unreachable(err) {
+			continue
+		}
+		if next != active && atomic.CompareAndSwapInt32(&s.active, int32(active), int32(next)) {
+			log.Printf("Sonar %s is unreachable, failed over to %s", s.urls[active], s.urls[next])
+		}
+		return err
+	}
+	return err
+}
+
+// unreachable reports whether error means Sonar can not be reached, either directly or through a load balancer
+func unreachable(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// executeAuthenticated executes request reloading credentials rejected by Sonar
+func (s *Client) executeAuthenticated(ctx context.Context, u string, res interface{}, cacheable bool) error {
+	err := s.executeOnce(ctx, u, res, cacheable)
+	if s.reload != nil && errors.Is(err, ErrUnauthorized) && s.reloadCredentials() {
+		return s.executeOnce(ctx, u, res, cacheable)
+	}
+	return err
+}
+
+// reloadCredentials obtains actual credentials. Returns true if they have changed
+func (s *Client) reloadCredentials() bool {
+	user, password, err := s.reload()
+	if err != nil {
+		log.Printf("Unable to reload credentials: %v", err)
+		return false
+	}
+
+	s.credentialsMut.Lock()
+	defer s.credentialsMut.Unlock()
+	if user == s.user && password == s.password {
+		return false
+	}
+	log.Println("Credentials rejected by Sonar have been reloaded")
+	s.user, s.password = user, password
+	return true
+}
+
+func (s *Client) executeOnce(ctx context.Context, u string, res interface{}, cacheable bool) error {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+	s.credentialsMut.RLock()
+	if s.user != "" {
+		rq.SetBasicAuth(s.user, s.password)
+	}
+	s.credentialsMut.RUnlock()
+	for name, values := range s.headers {
+		rq.Header[name] = values
+	}
+	requestID := newRequestID()
+	rq.Header.Set("User-Agent", s.userAgent)
+	rq.Header.Set("X-Request-Id", requestID)
+	var cached *cachedResponse
+	if cacheable {
+		cached = s.cached(u)
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			rq.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			rq.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	if atomic.LoadInt32(&s.limited) == 1 && atomic.AddInt64(&s.budget, -1) < 0 {
+		return fmt.Errorf("%w: GET %s", ErrBudgetExhausted, u)
+	}
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx, isCoreAPI(rq.URL.Path)); err != nil {
+			return fmt.Errorf("unable to execute request: %w", err)
+		}
+	}
+	log.Printf("GET [%s] request_id=%s", rq.URL.String(), requestID)
+	atomic.AddUint64(&s.requests, 1)
+	started := time.Now()
+
+	rs, err := s.c.Do(rq)
+	if err != nil {
+		s.observe(started, true)
+		return fmt.Errorf("unable to execute request: %w", err)
+	}
+	defer func() {
+		if rs.Body != nil {
+			if err := rs.Body.Close(); err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+	body, err := ioutil.ReadAll(rs.Body)
+	s.observe(started, err != nil || rs.StatusCode >= http.StatusInternalServerError || rs.StatusCode == http.StatusTooManyRequests)
+	if err != nil {
+		return fmt.Errorf("unable to read response: %w", err)
+	}
+	if rs.StatusCode >= 400 {
+		return newAPIError(rs, body)
+	}
+	switch {
+	case rs.StatusCode == http.StatusNotModified && cached != nil:
+		body = cached.body
+	case rs.StatusCode == http.StatusOK && cacheable:
+		s.store(u, rs, body)
+	}
+	// some errors are reported with successful status code
+	var errs errorsPayload
+	if err := json.Unmarshal(body, &errs); err == nil && len(errs.Errors) > 0 {
+		return &APIError{StatusCode: rs.StatusCode, Messages: errs.messages()}
+	}
+	if err := json.Unmarshal(body, res); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+	return nil
+}
+
+// cached returns cached response of URL. Nil if there is none
+func (s *Client) cached(u string) *cachedResponse {
+	s.cacheMut.Lock()
+	defer s.cacheMut.Unlock()
+	return s.cache[u]
+}
+
+// store caches response body if the response can be revalidated
+func (s *Client) store(u string, rs *http.Response, body []byte) {
+	etag, lastModified := rs.Header.Get("ETag"), rs.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+	s.cacheMut.Lock()
+	defer s.cacheMut.Unlock()
+	s.cache[u] = &cachedResponse{etag: etag, lastModified: lastModified, body: body}
+}
+
+// newRequestID generates random ID correlating exporter's requests with Sonar access logs
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
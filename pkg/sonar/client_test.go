@@ -0,0 +1,43 @@
+package sonar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		var requests int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(status)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"UP"}`))
+		}))
+
+		// backoff alone would outlast the context
+		client := sonar.NewClient(srv.URL, "", "", sonar.WithRetry(1, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		started := time.Now()
+		st, err := client.GetSystemStatusContext(ctx)
+		cancel()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("status %d: %v", status, err)
+		}
+		if st.Status != "UP" || atomic.LoadInt32(&requests) != 2 {
+			t.Errorf("status %d: got %q after %d requests, want UP after 2", status, st.Status, requests)
+		}
+		if elapsed := time.Since(started); elapsed < time.Second {
+			t.Errorf("status %d: retried after %s, want Retry-After of a second", status, elapsed)
+		}
+	}
+}
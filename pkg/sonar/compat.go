@@ -0,0 +1,53 @@
+package sonar
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// newCodePeriodIndex is an index of new code period in periods arrays returned by Sonar prior to 8.0
+const newCodePeriodIndex = 1
+
+// legacyPeriodModes maps new code period modes of Sonar prior to 8.0 to ones of 8.0+. Other modes are upper cased
+var legacyPeriodModes = map[string]string{
+	"days": "NUMBER_OF_DAYS",
+}
+
+// UnmarshalJSON decodes measures of any supported Sonar version. New code periods are returned differently
+// by versions, so the response is routed to the decoder of its shape:
+//   - prior to 8.0: periods arrays only, see decodeLegacyPeriods
+//   - 8.x and 9.x: periods arrays along with a single period, which are kept as is
+//   - 10.x: a single period only
+func (m *Measures) UnmarshalJSON(b []byte) error {
+	// alias drops methods, so decoding does not recurse
+	type measures Measures
+	if err := json.Unmarshal(b, (*measures)(m)); err != nil {
+		return err
+	}
+	if m.Period == nil && len(m.Periods) > 0 {
+		m.decodeLegacyPeriods()
+	}
+	return nil
+}
+
+// decodeLegacyPeriods fills single period of measures and values of new code measures from periods arrays,
+// as 8.0+ return them. Periods arrays are kept, so values of further periods are still available
+func (m *Measures) decodeLegacyPeriods() {
+	for _, p := range m.Periods {
+		if mode, found := legacyPeriodModes[p.Mode]; found {
+			p.Mode = mode
+		} else {
+			p.Mode = strings.ToUpper(p.Mode)
+		}
+		if p.Index == newCodePeriodIndex {
+			m.Period = p
+		}
+	}
+	for _, measure := range m.Component.Measures {
+		for _, p := range measure.Periods {
+			if p.Index == newCodePeriodIndex && measure.Period.Value == "" {
+				measure.Period.Value = p.Value
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package sonar_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// compatMeasures is a file of recorded measures response of compatibility fixtures
+const compatMeasures = "api_measures_component_40c30954a9f19c52.json"
+
+func TestDecodeMeasuresOfVersions(t *testing.T) {
+	for _, version := range []string{"7.9", "8.9", "9.9", "10.x"} {
+		t.Run(version, func(t *testing.T) {
+			body, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "compat", version, compatMeasures))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m sonar.Measures
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Fatal(err)
+			}
+			period := m.NewCodePeriod()
+			if period == nil || period.Mode != "PREVIOUS_VERSION" || period.Parameter != "2.2" {
+				t.Errorf("new code period %+v, want PREVIOUS_VERSION of 2.2", period)
+			}
+			for _, measure := range m.Component.Measures {
+				if measure.Metric != "new_coverage" {
+					continue
+				}
+				if measure.Period.Value != "64.2" || !measure.IsNewCode() {
+					t.Errorf("new code value %q, want 64.2", measure.Period.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeLegacyPeriods(t *testing.T) {
+	var m sonar.Measures
+	err := json.Unmarshal([]byte(`{
+		"component": {"key": "shop", "measures": [
+			{"metric": "new_bugs", "periods": [{"index": 1, "value": "2"}, {"index": 2, "value": "5"}]}
+		]},
+		"periods": [{"index": 1, "mode": "days", "parameter": "30"}, {"index": 2, "mode": "previous_analysis"}]
+	}`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Period == nil || m.Period.Mode != "NUMBER_OF_DAYS" || m.Period.Parameter != "30" {
+		t.Errorf("new code period %+v, want NUMBER_OF_DAYS of 30", m.Period)
+	}
+	if len(m.Periods) != 2 || m.Periods[1].Mode != "PREVIOUS_ANALYSIS" {
+		t.Errorf("periods %+v, want both kept with upper cased modes", m.Periods)
+	}
+	measure := m.Component.Measures[0]
+	if measure.Period.Value != "2" || len(measure.Periods) != 2 {
+		t.Errorf("new code value %q of %d periods, want 2 of 2 periods", measure.Period.Value, len(measure.Periods))
+	}
+}
@@ -0,0 +1,23 @@
+// Package sonar contains a client and data model for a subset of the SonarQube Web API:
+// components, measures, metrics, issues, branches and quality gates.
+//
+// Client is safe for concurrent use. Every request has a variant accepting context, e.g. GetMeasuresContext,
+// which cancels the request along with the context:
+//
+//	client := sonar.NewClient("https://sonar.example.com", token, "")
+//	measures, err := client.GetMeasuresContext(ctx, "my-project", []string{"coverage", "bugs"})
+//	if errors.Is(err, sonar.ErrNotFound) {
+//		// no such project
+//	}
+//
+// Failed requests return *APIError matching one of ErrUnauthorized, ErrForbidden, ErrNotFound and
+// ErrRateLimited with errors.Is. Search APIs returning results page by page are iterated with Paginate.
+//
+// Timeout, transport, retry policy and context path of Sonar behind a reverse proxy are configured with options:
+//
+//	client := sonar.NewClient("https://tools.example.com", token, "",
+//		sonar.WithBasePath("/sonar"), sonar.WithTimeout(30*time.Second), sonar.WithRetry(3, time.Second))
+//
+// Requests are limited by DefaultTimeout and are not retried by default. Failover URLs, credentials reload,
+// request budget and static headers are configured with Set* methods before the client is used.
+package sonar
@@ -0,0 +1,100 @@
+package sonar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrIncompleteResponse is returned when response lacks data required by the client
+	ErrIncompleteResponse = errors.New("incomplete response")
+	// ErrUnauthorized is returned when credentials are missing or invalid
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when credentials lack required permissions
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is returned when Sonar rejects request due to rate limiting
+	ErrRateLimited = errors.New("rate limited")
+	// ErrBudgetExhausted is returned without calling Sonar once request budget is spent, see SetBudget
+	ErrBudgetExhausted = errors.New("request budget exhausted")
+)
+
+// APIError is an error reported by Sonar API. Matches one of ErrUnauthorized, ErrForbidden,
+// ErrNotFound or ErrRateLimited via errors.Is depending on status code
+type APIError struct {
+	StatusCode int
+	Messages   []string
+	// RetryAfter is a delay requested by Sonar with Retry-After header of throttled or unavailable responses.
+	// Retries of the client wait for it instead of backoff
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("sonar API error. status code %d: %s", e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+// Unwrap returns kind of the error
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// IsTransient reports whether request failed due to an error which may go away on retry: network errors
+// including timeouts, throttling and server errors
+func IsTransient(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500) {
+		return true
+	}
+	return unreachable(err)
+}
+
+// newAPIError builds error from failed response body
+func newAPIError(rs *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: rs.StatusCode}
+
+	var errs errorsPayload
+	if err := json.Unmarshal(body, &errs); err == nil && len(errs.Errors) > 0 {
+		apiErr.Messages = errs.messages()
+	} else if len(body) > 0 {
+		apiErr.Messages = []string{string(body)}
+	}
+	retryAfter := rs.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(retryAfter); err == nil {
+		apiErr.RetryAfter = time.Until(at)
+	}
+	return apiErr
+}
+
+// errorsPayload is a standard Sonar error body, e.g. {"errors":[{"msg":"..."}]}
+type errorsPayload struct {
+	Errors []struct {
+		Msg string `json:"msg"`
+	} `json:"errors"`
+}
+
+func (p *errorsPayload) messages() []string {
+	msgs := make([]string, 0, len(p.Errors))
+	for _, e := range p.Errors {
+		msgs = append(msgs, e.Msg)
+	}
+	return msgs
+}
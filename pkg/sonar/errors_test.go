@@ -0,0 +1,123 @@
+package sonar_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		retryAfter string
+		messages   []string
+		kind       error
+		transient  bool
+		delayed    bool
+	}{
+		{
+			name:     "sonar errors",
+			status:   http.StatusBadRequest,
+			body:     `{"errors":[{"msg":"Value of parameter 'ps' (1000) must be less than 500"},{"msg":"Unknown metric"}]}`,
+			messages: []string{"Value of parameter 'ps' (1000) must be less than 500", "Unknown metric"},
+		},
+		{
+			name:     "unauthorized",
+			status:   http.StatusUnauthorized,
+			body:     `{"errors":[{"msg":"Authentication is required"}]}`,
+			messages: []string{"Authentication is required"},
+			kind:     sonar.ErrUnauthorized,
+		},
+		{
+			name:     "forbidden",
+			status:   http.StatusForbidden,
+			body:     `{"errors":[{"msg":"Insufficient privileges"}]}`,
+			messages: []string{"Insufficient privileges"},
+			kind:     sonar.ErrForbidden,
+		},
+		{
+			name:     "not found",
+			status:   http.StatusNotFound,
+			body:     `{"errors":[{"msg":"Component key 'shop' not found"}]}`,
+			messages: []string{"Component key 'shop' not found"},
+			kind:     sonar.ErrNotFound,
+		},
+		{
+			name:       "rate limited",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "30",
+			kind:       sonar.ErrRateLimited,
+			transient:  true,
+			delayed:    true,
+		},
+		{
+			name:       "unavailable until date",
+			status:     http.StatusServiceUnavailable,
+			body:       "<html><body>Maintenance</body></html>",
+			retryAfter: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+			messages:   []string{"<html><body>Maintenance</body></html>"},
+			transient:  true,
+			delayed:    true,
+		},
+		{
+			name:      "server error",
+			status:    http.StatusInternalServerError,
+			body:      "An error has occurred",
+			messages:  []string{"An error has occurred"},
+			transient: true,
+		},
+		{
+			name:     "empty errors",
+			status:   http.StatusConflict,
+			body:     `{"errors":[]}`,
+			messages: []string{`{"errors":[]}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := sonar.NewClient(srv.URL, "", "").GetSystemStatus()
+			var apiErr *sonar.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v, want APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || !reflect.DeepEqual(apiErr.Messages, tt.messages) {
+				t.Errorf("status %d with messages %q, want %d with %q", apiErr.StatusCode, apiErr.Messages, tt.status, tt.messages)
+			}
+			for _, kind := range []error{sonar.ErrUnauthorized, sonar.ErrForbidden, sonar.ErrNotFound, sonar.ErrRateLimited} {
+				if got := errors.Is(err, kind); got != (kind == tt.kind) {
+					t.Errorf("error is %v: %v", kind, got)
+				}
+			}
+			if got := sonar.IsTransient(err); got != tt.transient {
+				t.Errorf("transient %v, want %v", got, tt.transient)
+			}
+			if got := apiErr.RetryAfter > 0; got != tt.delayed {
+				t.Errorf("retry after %s, want delay %v", apiErr.RetryAfter, tt.delayed)
+			}
+		})
+	}
+}
+
+func TestUnreachableIsTransient(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if _, err := sonar.NewClient(srv.URL, "", "").GetSystemStatus(); err == nil || !sonar.IsTransient(err) {
+		t.Errorf("error %v of unreachable Sonar, want transient", err)
+	}
+}
@@ -0,0 +1,379 @@
+package sonar
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const sonarDateFormat = "2006-01-02T15:04:05-0700"
+
+type Paging struct {
+	PageIndex int `json:"pageIndex"`
+	PageSize  int `json:"pageSize"`
+	Total     int `json:"total"`
+}
+
+type ComponentInfo struct {
+	Organization string `json:"organization,omitempty"`
+	Key          string `json:"key,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Qualifier    string `json:"qualifier,omitempty"`
+	Project      string `json:"project,omitempty"`
+}
+
+type Component struct {
+	ComponentInfo
+	Description    string   `json:"description,omitempty"`
+	AnalysisDate   Date     `json:"analysisDate,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Visibility     string   `json:"visibility,omitempty"`
+	LeakPeriodDate Date     `json:"leakPeriodDate,omitempty"`
+	Version        string   `json:"version,omitempty"`
+	NeedIssueSync  bool     `json:"needIssueSync,omitempty"`
+}
+
+type Components struct {
+	Paging     *Paging          `json:"paging,omitempty"`
+	Components []*ComponentInfo `json:"components,omitempty"`
+}
+
+type Metrics struct {
+	Metrics []*Metric `json:"metrics,omitempty"`
+	Total   int       `json:"total"`
+	P       int       `json:"p"`
+	Ps      int       `json:"ps"`
+}
+
+type Metric struct {
+	ID          string `json:"id"`
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Domain      string `json:"domain"`
+	Direction   int    `json:"direction"`
+	Qualitative bool   `json:"qualitative"`
+	Hidden      bool   `json:"hidden"`
+	Custom      bool   `json:"custom"`
+}
+
+type Measures struct {
+	Component struct {
+		Key       string     `json:"key"`
+		Name      string     `json:"name"`
+		Qualifier string     `json:"qualifier"`
+		Language  string     `json:"language"`
+		Path      string     `json:"path"`
+		Measures  []*Measure `json:"measures"`
+	} `json:"component"`
+	Period *Period `json:"period"`
+	// Periods are returned by Sonar prior to 8.0 instead of Period
+	Periods []*Period `json:"periods"`
+	// Metrics are definitions of measured metrics
+	Metrics []*Metric `json:"metrics"`
+}
+
+// NewCodePeriod returns new code period of measures. Nil if unknown
+func (m *Measures) NewCodePeriod() *Period {
+	if m.Period != nil {
+		return m.Period
+	}
+	for _, p := range m.Periods {
+		if p.Index == newCodePeriodIndex {
+			return p
+		}
+	}
+	return nil
+}
+
+type Measure struct {
+	Metric string `json:"metric"`
+	Value  string `json:"value,omitempty"`
+	Period struct {
+		Value     string `json:"value"`
+		BestValue bool   `json:"bestValue"`
+	} `json:"period"`
+	// Periods are returned by Sonar prior to 8.0 instead of Period
+	Periods []struct {
+		Index int    `json:"index"`
+		Value string `json:"value"`
+	} `json:"periods"`
+}
+
+// NewCodeValue returns value of the measure on new code. Empty if the metric is not measured on new code
+func (m *Measure) NewCodeValue() string {
+	if m.Period.Value != "" {
+		return m.Period.Value
+	}
+	for _, p := range m.Periods {
+		if p.Index == newCodePeriodIndex {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// IsNewCode reports whether the measure is a new code one, e.g. new_coverage, having no overall value
+func (m *Measure) IsNewCode() bool {
+	return m.Value == "" && m.NewCodeValue() != ""
+}
+
+// PageInfo implements Page
+func (c *Components) PageInfo() *Paging {
+	return c.Paging
+}
+
+// Len implements Page
+func (c *Components) Len() int {
+	return len(c.Components)
+}
+
+type Issues struct {
+	Total  int      `json:"total"`
+	Paging *Paging  `json:"paging,omitempty"`
+	Facets []*Facet `json:"facets,omitempty"`
+	Issues []*Issue `json:"issues,omitempty"`
+}
+
+// Issue is a single issue. Component is a key of the file prefixed with project key and colon
+type Issue struct {
+	Key       string     `json:"key"`
+	Rule      string     `json:"rule"`
+	Severity  string     `json:"severity"`
+	Component string     `json:"component"`
+	Line      int        `json:"line,omitempty"`
+	TextRange *TextRange `json:"textRange,omitempty"`
+	Message   string     `json:"message"`
+	Type      string     `json:"type"`
+	Status    string     `json:"status"`
+}
+
+// TextRange is a location of issue within the file. Offsets are zero based
+type TextRange struct {
+	StartLine   int `json:"startLine"`
+	EndLine     int `json:"endLine"`
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+}
+
+// PageInfo implements Page
+func (i *Issues) PageInfo() *Paging {
+	return i.Paging
+}
+
+// Len implements Page
+func (i *Issues) Len() int {
+	return len(i.Issues)
+}
+
+// Count returns total number of issues matching the search
+func (i *Issues) Count() int {
+	if i.Paging != nil {
+		return i.Paging.Total
+	}
+	return i.Total
+}
+
+// Facet returns values of facet by its property. Nil if facet is absent
+func (i *Issues) Facet(property string) []*FacetValue {
+	for _, f := range i.Facets {
+		if f.Property == property {
+			return f.Values
+		}
+	}
+	return nil
+}
+
+type Facet struct {
+	Property string        `json:"property"`
+	Values   []*FacetValue `json:"values"`
+}
+
+type FacetValue struct {
+	Val   string `json:"val"`
+	Count int    `json:"count"`
+}
+
+type UserTokens struct {
+	Login      string       `json:"login"`
+	UserTokens []*UserToken `json:"userTokens"`
+}
+
+type UserToken struct {
+	Name               string `json:"name"`
+	CreatedAt          Date   `json:"createdAt"`
+	LastConnectionDate Date   `json:"lastConnectionDate"`
+	// ExpirationDate is zero if token never expires or Sonar does not support expiration
+	ExpirationDate Date `json:"expirationDate"`
+}
+
+type CustomMeasures struct {
+	CustomMeasures []*CustomMeasure `json:"customMeasures"`
+	Total          int              `json:"total"`
+	P              int              `json:"p"`
+	Ps             int              `json:"ps"`
+}
+
+// CustomMeasure is a value of custom (manual) metric entered by users.
+// Pending is true until the value is taken into account by the next analysis
+type CustomMeasure struct {
+	Metric struct {
+		Key  string `json:"key"`
+		Type string `json:"type"`
+	} `json:"metric"`
+	Value   string `json:"value"`
+	Pending bool   `json:"pending"`
+}
+
+type ProjectLinks struct {
+	Links []*ProjectLink `json:"links"`
+}
+
+// ProjectLink is a link of the project. Type is one of homepage, ci, issue, scm or custom.
+// Name is empty for links of predefined types
+type ProjectLink struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// PullRequests is a response of project pull requests API
+type PullRequests struct {
+	PullRequests []*PullRequest `json:"pullRequests"`
+}
+
+// PullRequest is an analyzed pull request of the project. Base is a branch the pull request targets
+type PullRequest struct {
+	Key    string             `json:"key"`
+	Title  string             `json:"title"`
+	Branch string             `json:"branch"`
+	Base   string             `json:"base"`
+	Status *PullRequestStatus `json:"status"`
+}
+
+// PullRequestStatus is a status of pull request analysis. QualityGateStatus is empty until the pull request is analyzed
+type PullRequestStatus struct {
+	QualityGateStatus string `json:"qualityGateStatus"`
+}
+
+// Branches are branches of a project
+type Branches struct {
+	Branches []*Branch `json:"branches"`
+}
+
+// Branch is a branch of a project. Type is BRANCH, or LONG and SHORT in Sonar versions prior to 8.1
+type Branch struct {
+	Name         string        `json:"name"`
+	IsMain       bool          `json:"isMain"`
+	Type         string        `json:"type"`
+	AnalysisDate Date          `json:"analysisDate,omitempty"`
+	Status       *BranchStatus `json:"status,omitempty"`
+}
+
+// BranchStatus is a quality gate status of a branch
+type BranchStatus struct {
+	QualityGateStatus string `json:"qualityGateStatus"`
+}
+
+// ProjectStatus is a quality gate status of the project along with statuses of gate conditions
+type ProjectStatus struct {
+	Status     string           `json:"status"`
+	Conditions []*GateCondition `json:"conditions"`
+}
+
+// GateCondition is a condition of quality gate. Comparator is either GT or LT. Thresholds of conditions on
+// new code are evaluated on the new code period
+type GateCondition struct {
+	Status         string `json:"status"`
+	MetricKey      string `json:"metricKey"`
+	Comparator     string `json:"comparator"`
+	ErrorThreshold string `json:"errorThreshold"`
+	ActualValue    string `json:"actualValue"`
+}
+
+// QualityGate is a quality gate assigned to the project. Default is set if project uses the default gate
+type QualityGate struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+}
+
+// SystemStatus is a status of Sonar server, e.g. UP, STARTING, RESTARTING, DOWN, DB_MIGRATION_NEEDED
+// or DB_MIGRATION_RUNNING
+type SystemStatus struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// ServerInfo describes Sonar server. Edition is empty for versions not reporting it
+type ServerInfo struct {
+	Version string `json:"version"`
+	Edition string `json:"edition"`
+}
+
+type Period struct {
+	// Index is a number of the period returned by Sonar prior to 8.0. New code period is the first one
+	Index     int    `json:"index,omitempty"`
+	Mode      string `json:"mode"`
+	Date      Date   `json:"date"`
+	Parameter string `json:"parameter"`
+}
+
+// Date is a time.Time wrapper which (un)marshals Sonar's date format.
+// Null and empty values are decoded as zero date
+type Date time.Time
+
+// dateFormats are formats returned by different SonarQube and SonarCloud versions
+var dateFormats = []string{
+	sonarDateFormat,
+	"2006-01-02T15:04:05Z0700",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+func (j *Date) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "" || s == "null" {
+		*j = Date{}
+		return nil
+	}
+
+	var err error
+	for _, format := range dateFormats {
+		var t time.Time
+		if t, err = time.Parse(format, s); err == nil {
+			*j = Date(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("unable to parse date: %w", err)
+}
+
+func (j Date) MarshalJSON() ([]byte, error) {
+	if j.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(j.format(sonarDateFormat))
+}
+
+// String formats date in Sonar's format accepted by API parameters
+func (j Date) String() string {
+	return j.format(sonarDateFormat)
+}
+
+// IsZero reports whether date is absent
+func (j Date) IsZero() bool {
+	return j.Time().IsZero()
+}
+
+func (j Date) format(s string) string {
+	return j.Time().Format(s)
+}
+
+// Time returns date as time.Time
+func (j Date) Time() time.Time {
+	return time.Time(j)
+}
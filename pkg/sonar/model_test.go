@@ -0,0 +1,65 @@
+package sonar_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+func TestDateUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    time.Time
+		invalid bool
+	}{
+		{name: "sonar", json: `"2023-04-05T10:20:30+0200"`, want: time.Date(2023, 4, 5, 8, 20, 30, 0, time.UTC)},
+		{name: "zulu", json: `"2023-04-05T10:20:30Z"`, want: time.Date(2023, 4, 5, 10, 20, 30, 0, time.UTC)},
+		{name: "rfc3339", json: `"2023-04-05T10:20:30.5+02:00"`, want: time.Date(2023, 4, 5, 8, 20, 30, 5e8, time.UTC)},
+		{name: "date only", json: `"2023-04-05"`, want: time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "empty", json: `""`},
+		{name: "null", json: `null`},
+		{name: "garbage", json: `"yesterday"`, invalid: true},
+		{name: "number", json: `1680682830`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// previous value must be replaced, empty values included
+			d := sonar.Date(time.Now())
+			err := json.Unmarshal([]byte(tt.json), &d)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("%s parsed as %v, want error", tt.json, d.Time())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !d.Time().Equal(tt.want) || d.IsZero() != tt.want.IsZero() {
+				t.Errorf("%s parsed as %v, want %v", tt.json, d.Time(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDateMarshalJSON(t *testing.T) {
+	var analysis struct {
+		Date sonar.Date `json:"date"`
+	}
+	b, err := json.Marshal(analysis)
+	if err != nil || string(b) != `{"date":null}` {
+		t.Errorf("zero date marshaled as %s, error %v, want null", b, err)
+	}
+
+	in := `{"date":"2023-04-05T10:20:30+0000"}`
+	if err := json.Unmarshal([]byte(in), &analysis); err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(analysis)
+	if err != nil || string(b) != in {
+		t.Errorf("date marshaled as %s, error %v, want %s", b, err, in)
+	}
+}
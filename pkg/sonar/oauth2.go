@@ -0,0 +1,132 @@
+package sonar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is a time before token expiration it is refreshed at
+const tokenExpiryMargin = 30 * time.Second
+
+// OAuth2Config configures OAuth2 client credentials flow of gateways Sonar is fronted with
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Header is a request header bearer token is sent in. Empty means Authorization,
+	// which replaces basic authentication of Sonar
+	Header string
+}
+
+// oauth2Transport obtains bearer tokens with client credentials flow and injects them into requests
+type oauth2Transport struct {
+	cfg  OAuth2Config
+	base http.RoundTripper
+
+	mut     sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewOAuth2Transport creates transport injecting bearer token obtained with client credentials flow into requests.
+// Token is refreshed before it expires or once it is rejected
+func NewOAuth2Transport(cfg OAuth2Config, base http.RoundTripper) http.RoundTripper {
+	if cfg.Header == "" {
+		cfg.Header = "Authorization"
+	}
+	return &oauth2Transport{cfg: cfg, base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *oauth2Transport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	token, err := t.getToken(rq.Context())
+	if err != nil {
+		return nil, err
+	}
+	// round trippers must not modify requests
+	rq = rq.Clone(rq.Context())
+	rq.Header.Set(t.cfg.Header, "Bearer "+token)
+
+	rs, err := t.base.RoundTrip(rq)
+	if err == nil && rs.StatusCode == http.StatusUnauthorized {
+		t.mut.Lock()
+		if t.token == token {
+			t.token = ""
+		}
+		t.mut.Unlock()
+	}
+	return rs, err
+}
+
+// getToken returns cached token or obtains new one if cached token expires soon
+func (t *oauth2Transport) getToken(ctx context.Context) (string, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	token, expiresIn, err := t.requestToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to obtain OAuth2 token: %w", err)
+	}
+	log.Println("OAuth2 token obtained")
+	t.token = token
+	// tokens without expiration are kept until rejected
+	t.expires = time.Now().Add(100 * 365 * 24 * time.Hour)
+	if expiresIn > 0 {
+		t.expires = time.Now().Add(expiresIn - tokenExpiryMargin)
+	}
+	return token, nil
+}
+
+// requestToken executes client credentials grant request
+func (t *oauth2Transport) requestToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(t.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(t.cfg.Scopes, " "))
+	}
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rq.SetBasicAuth(url.QueryEscape(t.cfg.ClientID), url.QueryEscape(t.cfg.ClientSecret))
+
+	rs, err := t.base.RoundTrip(rq)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err := rs.Body.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		return "", 0, err
+	}
+	if rs.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("status code %d: %s", rs.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", 0, fmt.Errorf("unable to decode token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, fmt.Errorf("%w: no access token", ErrIncompleteResponse)
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
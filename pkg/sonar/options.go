@@ -0,0 +1,62 @@
+package sonar
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout limits duration of API requests unless overridden with WithTimeout
+const DefaultTimeout = time.Minute
+
+// Option configures Client created with NewClient
+type Option func(*Client)
+
+// WithTimeout limits duration of every API request including reading of the response. Zero means no limit
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Client) {
+		s.c.Timeout = timeout
+	}
+}
+
+// WithTransport overrides transport used to execute API requests, e.g. to configure TLS or a proxy
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Client) {
+		s.c.Transport = rt
+	}
+}
+
+// WithRetry makes client retry requests failed due to network errors, throttling or server errors up to retries
+// times. Delay before the first retry is backoff and it doubles with every next one
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(s *Client) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// WithBasePath sets context path Sonar is served under behind a reverse proxy, e.g. /sonar, so the client
+// may be created with URL of the proxy itself. Applies to failover URLs as well
+func WithBasePath(path string) Option {
+	return func(s *Client) {
+		if path = strings.Trim(path, "/"); path != "" {
+			s.basePath = "/" + path
+		}
+	}
+}
+
+// baseURL normalizes base URL of Sonar and appends context path unless the URL ends with it already
+func (s *Client) baseURL(u string) string {
+	u = strings.TrimRight(strings.TrimSpace(u), "/")
+	if s.basePath != "" && !strings.HasSuffix(u, s.basePath) {
+		u += s.basePath
+	}
+	return u
+}
+
+// WithUserAgent overrides User-Agent header sent with API requests
+func WithUserAgent(userAgent string) Option {
+	return func(s *Client) {
+		s.userAgent = userAgent
+	}
+}
@@ -0,0 +1,113 @@
+package sonar
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coreAPIs are APIs measures are collected with. They are prioritized over the rest by RateLimiter
+var coreAPIs = map[string]struct{}{
+	"/api/components/search":          {},
+	"/api/components/search_projects": {},
+	"/api/components/show":            {},
+	"/api/metrics/search":             {},
+	"/api/measures/component":         {},
+}
+
+// isCoreAPI reports whether URL path is one of core APIs. Sonar may be served under a context path, e.g. /sonar/api
+func isCoreAPI(path string) bool {
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i:]
+	}
+	_, found := coreAPIs[path]
+	return found
+}
+
+// RateLimiter limits rate of API requests shared by core APIs measures are collected with and the rest
+// of APIs, e.g. issues. Rate is split into two token buckets by weight. Core requests take tokens of the other
+// bucket once their own one is empty, while the rest never take core tokens, so they can not starve core requests
+type RateLimiter struct {
+	mut      sync.Mutex
+	core     bucket
+	optional bucket
+}
+
+// bucket is a token bucket refilled at rate tokens per second holding up to a second worth of tokens
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates limiter of qps requests per second. coreShare is a share of rate, from 0 to 1 exclusive,
+// reserved for core APIs
+func NewRateLimiter(qps, coreShare float64) *RateLimiter {
+	now := time.Now()
+	core, optional := qps*coreShare, qps*(1-coreShare)
+	return &RateLimiter{
+		core:     bucket{rate: core, tokens: math.Max(core, 1), last: now},
+		optional: bucket{rate: optional, tokens: math.Max(optional, 1), last: now},
+	}
+}
+
+// refill adds tokens accumulated since the last refill
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(math.Max(b.rate, 1), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take takes a token if available
+func (b *bucket) take() bool {
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// delay returns time left until a token is available
+func (b *bucket) delay() time.Duration {
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Wait blocks until request of the class may be executed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, core bool) error {
+	for {
+		delay := l.reserve(core)
+		if delay == 0 {
+			return nil
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// reserve takes a token for request of the class. Returns zero if taken or delay before the next attempt otherwise
+func (l *RateLimiter) reserve(core bool) time.Duration {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	now := time.Now()
+	l.core.refill(now)
+	l.optional.refill(now)
+	if core {
+		if l.core.take() || l.optional.take() {
+			return 0
+		}
+		if d := l.optional.delay(); d < l.core.delay() {
+			return d
+		}
+		return l.core.delay()
+	}
+	if l.optional.take() {
+		return 0
+	}
+	return l.optional.delay()
+}
@@ -0,0 +1,103 @@
+package sonar
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// recordingTransport saves successful responses to a directory
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecordingTransport returns transport which saves successful API responses to the directory,
+// so they can be served later by transport created with NewReplayTransport
+func NewRecordingTransport(dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create record directory: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{dir: dir, next: next}, nil
+}
+
+func (t *recordingTransport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	rs, err := t.next.RoundTrip(rq)
+	if err != nil || rs.StatusCode != http.StatusOK {
+		return rs, err
+	}
+
+	body, err := ioutil.ReadAll(rs.Body)
+	if cErr := rs.Body.Close(); cErr != nil {
+		log.Print(cErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response: %w", err)
+	}
+	rs.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := ioutil.WriteFile(filepath.Join(t.dir, recordFileName(rq.URL)), body, 0o600); err != nil {
+		log.Printf("Unable to record response of %s: %v", rq.URL, err)
+	}
+	return rs, nil
+}
+
+// replayTransport serves responses from a directory
+type replayTransport struct {
+	dir string
+}
+
+// NewReplayTransport returns transport which serves API responses recorded by NewRecordingTransport
+// instead of calling Sonar. Requests which were not recorded get 404 response
+func NewReplayTransport(dir string) (http.RoundTripper, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("unable to open replay directory: %w", err)
+	}
+	return &replayTransport{dir: dir}, nil
+}
+
+func (t *replayTransport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	body, err := ioutil.ReadFile(filepath.Join(t.dir, recordFileName(rq.URL)))
+	if err != nil {
+		status = http.StatusNotFound
+		body = []byte(fmt.Sprintf(`{"errors":[{"msg":"no recorded response for %s"}]}`, rq.URL.RequestURI()))
+	}
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       rq,
+	}, nil
+}
+
+// recordFileName builds file name from API path and query, e.g. api_measures_component_<query hash>.json.
+// Base path of Sonar server is ignored so responses may be replayed against any URL
+func recordFileName(u *url.URL) string {
+	path := u.Path
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i:]
+	}
+	name := strings.ReplaceAll(strings.Trim(path, "/"), "/", "_")
+	if u.RawQuery == "" {
+		return name + ".json"
+	}
+	h := sha1.Sum([]byte(u.Query().Encode())) // nolint:gosec
+	return name + "_" + hex.EncodeToString(h[:8]) + ".json"
+}
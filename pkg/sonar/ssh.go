@@ -0,0 +1,127 @@
+package sonar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig configures SSH jump host connections to Sonar are tunneled through
+type SSHConfig struct {
+	// Addr is a host:port of the jump host
+	Addr string
+	User string
+	// KeyFile is a file of unencrypted private key the user is authenticated with
+	KeyFile string
+	// KnownHostsFile is a file of known host keys the jump host is verified against. Empty means ~/.ssh/known_hosts
+	KnownHostsFile string
+}
+
+// sshDialer opens connections through a single SSH connection to the jump host, which is established
+// on the first dial and reestablished once it drops
+type sshDialer struct {
+	addr   string
+	config *ssh.ClientConfig
+	dialer *net.Dialer
+
+	mut    sync.Mutex
+	client *ssh.Client
+}
+
+// newSSHDialer creates dialer of the jump host authenticated with key of cfg
+func newSSHDialer(cfg SSHConfig, dialer *net.Dialer) (*sshDialer, error) {
+	key, err := ioutil.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse SSH key %s: %w", cfg.KeyFile, err)
+	}
+	knownHosts := cfg.KnownHostsFile
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to locate known hosts: %w", err)
+		}
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts: %w", err)
+	}
+	return &sshDialer{
+		addr: cfg.Addr,
+		config: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeys,
+			Timeout:         dialer.Timeout,
+		},
+		dialer: dialer,
+	}, nil
+}
+
+// DialContext connects to addr from the jump host. Host names of addr are resolved by the jump host
+func (d *sshDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial(network, addr)
+	var rejected *ssh.OpenChannelError
+	if err != nil && !errors.As(err, &rejected) {
+		// connection to the jump host is broken, so the next dial reconnects
+		d.drop(client)
+	}
+	return conn, err
+}
+
+// connect returns connection to the jump host establishing it if needed
+func (d *sshDialer) connect(ctx context.Context) (*ssh.Client, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach SSH jump host: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to connect to SSH jump host: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	go func() {
+		_ = client.Wait()
+		d.drop(client)
+	}()
+	d.client = client
+	return client, nil
+}
+
+// drop closes connection to the jump host unless it is replaced already
+func (d *sshDialer) drop(client *ssh.Client) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	if d.client == client {
+		d.client = nil
+	}
+	client.Close()
+}
@@ -0,0 +1,209 @@
+package sonar_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// jumpHost is an in-process SSH server forwarding direct-tcpip channels of a single authorized key
+type jumpHost struct {
+	addr    string
+	hostKey ssh.PublicKey
+	// tunnels is a number of forwarded channels. Accessed atomically
+	tunnels int32
+}
+
+// newKey generates ECDSA key
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// startJumpHost starts SSH server accepting the user authenticated with authorized key
+func startJumpHost(t *testing.T, user string, authorized ssh.PublicKey) *jumpHost {
+	t.Helper()
+	hostSigner, err := ssh.NewSignerFromKey(newKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == user && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	jh := &jumpHost{addr: l.Addr().String(), hostKey: hostSigner.PublicKey()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go jh.serve(conn, config)
+		}
+	}()
+	return jh
+}
+
+// serve forwards direct-tcpip channels of SSH connection
+func (jh *jumpHost) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			_ = ch.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &target); err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, chReqs, err := ch.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		atomic.AddInt32(&jh.tunnels, 1)
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			_, _ = io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// writeFile writes content to a file of dir and returns its path
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSSHJumpHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"UP"}`))
+	}))
+	defer srv.Close()
+
+	clientKey := newKey(t)
+	der, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, err := ssh.NewPublicKey(&clientKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jh := startJumpHost(t, "exporter", clientPub)
+
+	dir := t.TempDir()
+	keyFile := writeFile(t, dir, "id_ecdsa", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	knownHosts := writeFile(t, dir, "known_hosts", []byte(knownhosts.Line([]string{jh.addr}, jh.hostKey)+"\n"))
+	strangerKey, err := ssh.NewPublicKey(&newKey(t).PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strangers := writeFile(t, dir, "strangers", []byte(knownhosts.Line([]string{jh.addr}, strangerKey)+"\n"))
+
+	tests := []struct {
+		name       string
+		user       string
+		knownHosts string
+		tunneled   bool
+	}{
+		{name: "tunneled", user: "exporter", knownHosts: knownHosts, tunneled: true},
+		{name: "unknown host key", user: "exporter", knownHosts: strangers},
+		{name: "unauthorized user", user: "stranger", knownHosts: knownHosts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt32(&jh.tunnels)
+			transport, err := sonar.NewTransport(sonar.TransportConfig{
+				SSH: &sonar.SSHConfig{Addr: jh.addr, User: tt.user, KeyFile: keyFile, KnownHostsFile: tt.knownHosts},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer transport.CloseIdleConnections()
+			// requests go through the proxy otherwise
+			transport.Proxy = nil
+			client := sonar.NewClient(srv.URL, "", "", sonar.WithTransport(transport))
+
+			st, err := client.GetSystemStatus()
+			tunnels := atomic.LoadInt32(&jh.tunnels) - before
+			if !tt.tunneled {
+				if err == nil || tunnels != 0 {
+					t.Errorf("request succeeded through %d tunnels, want failure", tunnels)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Status != "UP" || tunnels != 1 {
+				t.Errorf("got %q through %d tunnels, want UP through 1", st.Status, tunnels)
+			}
+		})
+	}
+}
+
+func TestSSHKeyRequired(t *testing.T) {
+	_, err := sonar.NewTransport(sonar.TransportConfig{
+		SSH: &sonar.SSHConfig{Addr: "127.0.0.1:22", User: "exporter", KeyFile: filepath.Join(t.TempDir(), "missing")},
+	})
+	if err == nil {
+		t.Error("transport created with missing SSH key")
+	}
+}
@@ -0,0 +1,114 @@
+package sonartest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// Issue is a fake issue of a project. Resolution is empty for open issues
+type Issue struct {
+	Key        string
+	Rule       string
+	Severity   string
+	Type       string
+	Message    string
+	File       string
+	Line       int
+	Resolution string
+	Created    time.Time
+}
+
+// issueFacets extract facet values of an issue by facet property
+var issueFacets = map[string]func(*Issue) string{
+	"rules":       func(i *Issue) string { return i.Rule },
+	"severities":  func(i *Issue) string { return i.Severity },
+	"types":       func(i *Issue) string { return i.Type },
+	"resolutions": func(i *Issue) string { return i.Resolution },
+}
+
+// searchIssues serves issues of a single project filtered by resolved, resolutions and createdAfter
+// along with requested facets
+func (s *Server) searchIssues(w http.ResponseWriter, rq *http.Request) {
+	q := rq.URL.Query()
+	p, found := s.project(q.Get("componentKeys"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Component key '%s' not found", q.Get("componentKeys")))
+		return
+	}
+	var createdAfter time.Time
+	if after := q.Get("createdAfter"); after != "" {
+		var d sonar.Date
+		if err := d.UnmarshalJSON([]byte(`"` + after + `"`)); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid createdAfter: %v", err))
+			return
+		}
+		createdAfter = d.Time()
+	}
+
+	var matched []*Issue
+	for _, i := range p.Issues {
+		switch {
+		case q.Get("resolved") == "false" && i.Resolution != "",
+			q.Get("resolved") == "true" && i.Resolution == "",
+			q.Get("resolutions") != "" && !contains(strings.Split(q.Get("resolutions"), ","), i.Resolution),
+			!createdAfter.IsZero() && i.Created.Before(createdAfter):
+			continue
+		}
+		matched = append(matched, i)
+	}
+
+	page, size, from, to := paging(rq, len(matched))
+	res := &sonar.Issues{
+		Total:  len(matched),
+		Paging: &sonar.Paging{PageIndex: page, PageSize: size, Total: len(matched)},
+		Issues: make([]*sonar.Issue, 0, to-from),
+	}
+	for _, i := range matched[from:to] {
+		res.Issues = append(res.Issues, &sonar.Issue{
+			Key: i.Key, Rule: i.Rule, Severity: i.Severity, Type: i.Type, Message: i.Message,
+			Component: p.Key + ":" + i.File, Line: i.Line, Status: issueStatus(i),
+		})
+	}
+	if facets := q.Get("facets"); facets != "" {
+		for _, property := range strings.Split(facets, ",") {
+			if value, found := issueFacets[property]; found {
+				res.Facets = append(res.Facets, facet(property, matched, value))
+			}
+		}
+	}
+	writeJSON(w, res)
+}
+
+// facet counts issues by value, most frequent values first
+func facet(property string, issues []*Issue, value func(*Issue) string) *sonar.Facet {
+	counts := map[string]int{}
+	for _, i := range issues {
+		if v := value(i); v != "" {
+			counts[v]++
+		}
+	}
+	f := &sonar.Facet{Property: property, Values: make([]*sonar.FacetValue, 0, len(counts))}
+	for v, count := range counts {
+		f.Values = append(f.Values, &sonar.FacetValue{Val: v, Count: count})
+	}
+	sort.Slice(f.Values, func(i, j int) bool {
+		if f.Values[i].Count != f.Values[j].Count {
+			return f.Values[i].Count > f.Values[j].Count
+		}
+		return f.Values[i].Val < f.Values[j].Val
+	})
+	return f
+}
+
+// issueStatus returns status of the issue, OPEN or RESOLVED
+func issueStatus(i *Issue) string {
+	if i.Resolution == "" {
+		return "OPEN"
+	}
+	return "RESOLVED"
+}
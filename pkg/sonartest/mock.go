@@ -0,0 +1,239 @@
+package sonartest
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+// MockAPI is an exporter.SonarAPI implementation for unit tests of code embedding the collector,
+// which need no server at all. Every API method calls its func. Methods without func fail with sonar.ErrNotFound.
+// Safe for concurrent use once funcs are set
+type MockAPI struct {
+	GetComponentsFunc         func() ([]*sonar.ComponentInfo, error)
+	SearchProjectsFunc        func(filter, sort string) ([]*sonar.ComponentInfo, error)
+	GetPortfoliosFunc         func() ([]*sonar.ComponentInfo, error)
+	GetComponentFunc          func(key string) (*sonar.Component, error)
+	GetMetricsFunc            func() ([]*sonar.Metric, error)
+	GetMeasuresFunc           func(key string, metrics []string) (*sonar.Measures, error)
+	SearchIssuesFunc          func(query url.Values) (*sonar.Issues, error)
+	GetOpenIssuesFunc         func(key string) ([]*sonar.Issue, error)
+	GetUserTokensFunc         func() ([]*sonar.UserToken, error)
+	GetCustomMeasuresFunc     func(key string) ([]*sonar.CustomMeasure, error)
+	GetProjectLinksFunc       func(key string) ([]*sonar.ProjectLink, error)
+	GetPullRequestsFunc       func(key string) ([]*sonar.PullRequest, error)
+	GetProjectStatusFunc      func(key string) (*sonar.ProjectStatus, error)
+	GetProjectQualityGateFunc func(key string) (*sonar.QualityGate, error)
+	GetServerInfoFunc         func() (*sonar.ServerInfo, error)
+	GetSystemStatusFunc       func() (*sonar.SystemStatus, error)
+
+	// BaseURL is returned by URL and URLs. Defaults to http://sonar.test
+	BaseURL string
+
+	mut      sync.Mutex
+	calls    map[string]int
+	requests uint64
+	limited  bool
+	budget   int64
+}
+
+// MockAPI must keep up with exporter.SonarAPI, see TestMockFuncs for funcs of its methods
+var _ exporter.SonarAPI = (*MockAPI)(nil)
+
+// Calls returns number of calls of API method, e.g. GetMeasures
+func (m *MockAPI) Calls(method string) int {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.calls[method]
+}
+
+// call accounts call of the method. Fails once request budget is spent or if the method is not mocked
+func (m *MockAPI) call(method string, mocked bool) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if m.limited {
+		if m.budget <= 0 {
+			return fmt.Errorf("%w: %s", sonar.ErrBudgetExhausted, method)
+		}
+		m.budget--
+	}
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[method]++
+	m.requests++
+	if !mocked {
+		return fmt.Errorf("%w: %s is not mocked", sonar.ErrNotFound, method)
+	}
+	return nil
+}
+
+// GetComponents calls GetComponentsFunc
+func (m *MockAPI) GetComponents() ([]*sonar.ComponentInfo, error) {
+	if err := m.call("GetComponents", m.GetComponentsFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetComponentsFunc()
+}
+
+// SearchProjects calls SearchProjectsFunc
+func (m *MockAPI) SearchProjects(filter, sort string) ([]*sonar.ComponentInfo, error) {
+	if err := m.call("SearchProjects", m.SearchProjectsFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.SearchProjectsFunc(filter, sort)
+}
+
+// GetPortfolios calls GetPortfoliosFunc
+func (m *MockAPI) GetPortfolios() ([]*sonar.ComponentInfo, error) {
+	if err := m.call("GetPortfolios", m.GetPortfoliosFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetPortfoliosFunc()
+}
+
+// GetComponent calls GetComponentFunc
+func (m *MockAPI) GetComponent(key string) (*sonar.Component, error) {
+	if err := m.call("GetComponent", m.GetComponentFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetComponentFunc(key)
+}
+
+// GetMetrics calls GetMetricsFunc
+func (m *MockAPI) GetMetrics() ([]*sonar.Metric, error) {
+	if err := m.call("GetMetrics", m.GetMetricsFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetMetricsFunc()
+}
+
+// GetMeasures calls GetMeasuresFunc
+func (m *MockAPI) GetMeasures(key string, metrics []string) (*sonar.Measures, error) {
+	if err := m.call("GetMeasures", m.GetMeasuresFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetMeasuresFunc(key, metrics)
+}
+
+// SearchIssues calls SearchIssuesFunc
+func (m *MockAPI) SearchIssues(query url.Values) (*sonar.Issues, error) {
+	if err := m.call("SearchIssues", m.SearchIssuesFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.SearchIssuesFunc(query)
+}
+
+// GetOpenIssues calls GetOpenIssuesFunc
+func (m *MockAPI) GetOpenIssues(key string) ([]*sonar.Issue, error) {
+	if err := m.call("GetOpenIssues", m.GetOpenIssuesFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetOpenIssuesFunc(key)
+}
+
+// GetUserTokens calls GetUserTokensFunc
+func (m *MockAPI) GetUserTokens() ([]*sonar.UserToken, error) {
+	if err := m.call("GetUserTokens", m.GetUserTokensFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetUserTokensFunc()
+}
+
+// GetCustomMeasures calls GetCustomMeasuresFunc
+func (m *MockAPI) GetCustomMeasures(key string) ([]*sonar.CustomMeasure, error) {
+	if err := m.call("GetCustomMeasures", m.GetCustomMeasuresFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetCustomMeasuresFunc(key)
+}
+
+// GetProjectLinks calls GetProjectLinksFunc
+func (m *MockAPI) GetProjectLinks(key string) ([]*sonar.ProjectLink, error) {
+	if err := m.call("GetProjectLinks", m.GetProjectLinksFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetProjectLinksFunc(key)
+}
+
+// GetPullRequests calls GetPullRequestsFunc
+func (m *MockAPI) GetPullRequests(key string) ([]*sonar.PullRequest, error) {
+	if err := m.call("GetPullRequests", m.GetPullRequestsFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetPullRequestsFunc(key)
+}
+
+// GetProjectStatus calls GetProjectStatusFunc
+func (m *MockAPI) GetProjectStatus(key string) (*sonar.ProjectStatus, error) {
+	if err := m.call("GetProjectStatus", m.GetProjectStatusFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetProjectStatusFunc(key)
+}
+
+// GetProjectQualityGate calls GetProjectQualityGateFunc
+func (m *MockAPI) GetProjectQualityGate(key string) (*sonar.QualityGate, error) {
+	if err := m.call("GetProjectQualityGate", m.GetProjectQualityGateFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetProjectQualityGateFunc(key)
+}
+
+// GetServerInfo calls GetServerInfoFunc
+func (m *MockAPI) GetServerInfo() (*sonar.ServerInfo, error) {
+	if err := m.call("GetServerInfo", m.GetServerInfoFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetServerInfoFunc()
+}
+
+// GetSystemStatus calls GetSystemStatusFunc
+func (m *MockAPI) GetSystemStatus() (*sonar.SystemStatus, error) {
+	if err := m.call("GetSystemStatus", m.GetSystemStatusFunc != nil); err != nil {
+		return nil, err
+	}
+	return m.GetSystemStatusFunc()
+}
+
+// Requests returns number of API method calls
+func (m *MockAPI) Requests() uint64 {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.requests
+}
+
+// Failures always returns zero
+func (m *MockAPI) Failures() uint64 {
+	return 0
+}
+
+// Latency always returns zero
+func (m *MockAPI) Latency() time.Duration {
+	return 0
+}
+
+// SetBudget limits number of API method calls. Calls beyond the budget fail with sonar.ErrBudgetExhausted.
+// Negative budget removes the limit
+func (m *MockAPI) SetBudget(budget int64) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.limited, m.budget = budget >= 0, budget
+}
+
+// URLs returns BaseURL
+func (m *MockAPI) URLs() []string {
+	return []string{m.URL()}
+}
+
+// URL returns BaseURL
+func (m *MockAPI) URL() string {
+	if m.BaseURL == "" {
+		return "http://sonar.test"
+	}
+	return m.BaseURL
+}
@@ -0,0 +1,31 @@
+package sonartest_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonartest"
+)
+
+// TestMockFuncs makes sure every Sonar API method of exporter.SonarAPI can be mocked with a func of the same signature
+func TestMockFuncs(t *testing.T) {
+	api := reflect.TypeOf((*exporter.SonarAPI)(nil)).Elem()
+	mock := reflect.TypeOf(sonartest.MockAPI{})
+	for i := 0; i < api.NumMethod(); i++ {
+		method := api.Method(i)
+		// accounting methods are implemented by the mock itself
+		if !strings.HasPrefix(method.Name, "Get") && !strings.HasPrefix(method.Name, "Search") {
+			continue
+		}
+		field, found := mock.FieldByName(method.Name + "Func")
+		if !found {
+			t.Errorf("MockAPI lacks %sFunc", method.Name)
+			continue
+		}
+		if field.Type != method.Type {
+			t.Errorf("%sFunc is %s, want %s", method.Name, field.Type, method.Type)
+		}
+	}
+}
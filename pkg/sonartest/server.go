@@ -0,0 +1,422 @@
+// Package sonartest provides in-process fake SonarQube server for deterministic tests
+// of code built on top of the exporter. The server serves projects and portfolios, metrics, measures, issues,
+// quality gates, links, pull requests, user tokens, server info and status. MockAPI replaces the server
+// in unit tests needing no HTTP at all
+package sonartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+)
+
+const defaultPageSize = 100
+
+// Project is a fake Sonar project
+type Project struct {
+	Key  string
+	Name string
+	// Qualifier is TRK for projects, the default, or VW for portfolios
+	Qualifier    string
+	Tags         []string
+	AnalysisDate time.Time
+	// Measures are overall values by metric key
+	Measures map[string]string
+	// NewMeasures are new code period values by metric key
+	NewMeasures map[string]string
+	// Issues are issues of the project, both open and resolved
+	Issues []*Issue
+	// GateStatus is a quality gate status, e.g. OK or ERROR. Empty means NONE
+	GateStatus string
+	// GateConditions are conditions of the quality gate
+	GateConditions []*sonar.GateCondition
+	// QualityGate is a quality gate assigned to the project. Nil means the default one
+	QualityGate  *sonar.QualityGate
+	Links        []*sonar.ProjectLink
+	PullRequests []*sonar.PullRequest
+}
+
+// qualifier returns qualifier of the project, TRK unless set
+func (p *Project) qualifier() string {
+	if p.Qualifier == "" {
+		return "TRK"
+	}
+	return p.Qualifier
+}
+
+// Server is a fake Sonar server. Safe for concurrent use
+type Server struct {
+	srv *httptest.Server
+
+	mut      sync.RWMutex
+	metrics  []*sonar.Metric
+	projects map[string]*Project
+	user     string
+	password string
+	latency  time.Duration
+	failures map[string]int
+	requests int
+	// status is a status reported by api/system/status
+	status string
+	// tokens are tokens of the authenticated user
+	tokens []*sonar.UserToken
+}
+
+// NewServer starts fake server serving provided metrics and projects
+func NewServer(metrics []*sonar.Metric, projects ...*Project) *Server {
+	s := &Server{
+		metrics:  metrics,
+		projects: map[string]*Project{},
+		failures: map[string]int{},
+		status:   "UP",
+	}
+	for _, p := range projects {
+		s.projects[p.Key] = p
+	}
+
+	m := http.NewServeMux()
+	m.HandleFunc("/api/components/search", s.searchComponents)
+	m.HandleFunc("/api/components/show", s.showComponent)
+	m.HandleFunc("/api/metrics/search", s.searchMetrics)
+	m.HandleFunc("/api/measures/component", s.componentMeasures)
+	m.HandleFunc("/api/system/status", s.systemStatus)
+	m.HandleFunc("/api/navigation/global", s.serverInfo)
+	m.HandleFunc("/api/issues/search", s.searchIssues)
+	m.HandleFunc("/api/qualitygates/project_status", s.projectStatus)
+	m.HandleFunc("/api/qualitygates/get_by_project", s.projectQualityGate)
+	m.HandleFunc("/api/project_links/search", s.projectLinks)
+	m.HandleFunc("/api/project_pull_requests/list", s.pullRequests)
+	m.HandleFunc("/api/user_tokens/search", s.userTokens)
+	s.srv = httptest.NewServer(s.middleware(m))
+	return s
+}
+
+// DefaultMetrics returns a small set of common Sonar metrics
+func DefaultMetrics() []*sonar.Metric {
+	return []*sonar.Metric{
+		{ID: "1", Key: "coverage", Type: "PERCENT", Name: "Coverage", Description: "Coverage by tests", Domain: "Coverage"},
+		{ID: "2", Key: "bugs", Type: "INT", Name: "Bugs", Description: "Bugs", Domain: "Reliability"},
+		{ID: "3", Key: "vulnerabilities", Type: "INT", Name: "Vulnerabilities", Description: "Vulnerabilities", Domain: "Security"},
+		{ID: "4", Key: "code_smells", Type: "INT", Name: "Code Smells", Description: "Code Smells", Domain: "Maintainability"},
+		{ID: "5", Key: "ncloc", Type: "INT", Name: "Lines of Code", Description: "Non commenting lines of code", Domain: "Size"},
+		{
+			ID: "6", Key: "alert_status", Type: "LEVEL", Name: "Quality Gate Status",
+			Description: "The project status with regard to its quality gate.", Domain: "Releasability",
+		},
+		{ID: "7", Key: "new_coverage", Type: "PERCENT", Name: "Coverage on New Code", Description: "Coverage of new/changed code", Domain: "Coverage"},
+	}
+}
+
+// URL returns base URL of the server
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Requests returns number of requests served
+func (s *Server) Requests() int {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.requests
+}
+
+// SetCredentials makes server require basic auth. Empty user disables authentication
+func (s *Server) SetCredentials(user, password string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.user, s.password = user, password
+}
+
+// SetLatency delays every response
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.latency = latency
+}
+
+// SetFailure makes requests to API path (e.g. /api/measures/component) fail with status code.
+// Zero status code removes the failure
+func (s *Server) SetFailure(path string, status int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if status == 0 {
+		delete(s.failures, path)
+		return
+	}
+	s.failures[path] = status
+}
+
+// SetStatus sets status reported by api/system/status, e.g. DB_MIGRATION_RUNNING to simulate an upgrade. Defaults to UP
+func (s *Server) SetStatus(status string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.status = status
+}
+
+// SetProject adds or replaces project
+func (s *Server) SetProject(p *Project) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.projects[p.Key] = p
+}
+
+// SetUserTokens sets tokens of the authenticated user
+func (s *Server) SetUserTokens(tokens ...*sonar.UserToken) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.tokens = tokens
+}
+
+// RemoveProject removes project
+func (s *Server) RemoveProject(key string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	delete(s.projects, key)
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		s.mut.Lock()
+		s.requests++
+		latency, status := s.latency, s.failures[rq.URL.Path]
+		user, password := s.user, s.password
+		s.mut.Unlock()
+
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+		if user != "" {
+			if u, p, ok := rq.BasicAuth(); !ok || u != user || p != password {
+				writeError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+		}
+		if status != 0 {
+			writeError(w, status, fmt.Sprintf("Simulated failure of %s", rq.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, rq)
+	})
+}
+
+func (s *Server) searchComponents(w http.ResponseWriter, rq *http.Request) {
+	qualifiers := strings.Split(rq.URL.Query().Get("qualifiers"), ",")
+	if rq.URL.Query().Get("qualifiers") == "" {
+		qualifiers = []string{"TRK"}
+	}
+	s.mut.RLock()
+	keys := make([]string, 0, len(s.projects))
+	for key, p := range s.projects {
+		if contains(qualifiers, p.qualifier()) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	page, size, from, to := paging(rq, len(keys))
+	components := make([]*sonar.ComponentInfo, 0, to-from)
+	for _, key := range keys[from:to] {
+		p := s.projects[key]
+		components = append(components, &sonar.ComponentInfo{Key: p.Key, Name: p.Name, Qualifier: p.qualifier()})
+	}
+	s.mut.RUnlock()
+
+	writeJSON(w, &sonar.Components{
+		Paging:     &sonar.Paging{PageIndex: page, PageSize: size, Total: len(keys)},
+		Components: components,
+	})
+}
+
+func (s *Server) showComponent(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("component"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Component key '%s' not found", rq.URL.Query().Get("component")))
+		return
+	}
+	writeJSON(w, map[string]interface{}{"component": &sonar.Component{
+		ComponentInfo: sonar.ComponentInfo{Key: p.Key, Name: p.Name, Qualifier: p.qualifier()},
+		AnalysisDate:  sonar.Date(p.AnalysisDate),
+		Tags:          p.Tags,
+		Visibility:    "public",
+	}})
+}
+
+func (s *Server) systemStatus(w http.ResponseWriter, _ *http.Request) {
+	s.mut.RLock()
+	status := s.status
+	s.mut.RUnlock()
+	writeJSON(w, &sonar.SystemStatus{ID: "fake", Version: "9.9.0", Status: status})
+}
+
+func (s *Server) searchMetrics(w http.ResponseWriter, rq *http.Request) {
+	s.mut.RLock()
+	page, size, from, to := paging(rq, len(s.metrics))
+	metrics := s.metrics[from:to]
+	total := len(s.metrics)
+	s.mut.RUnlock()
+
+	writeJSON(w, &sonar.Metrics{Metrics: metrics, Total: total, P: page, Ps: size})
+}
+
+func (s *Server) componentMeasures(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("component"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Component key '%s' not found", rq.URL.Query().Get("component")))
+		return
+	}
+
+	measures := []map[string]interface{}{}
+	for _, key := range strings.Split(rq.URL.Query().Get("metricKeys"), ",") {
+		if val, found := p.Measures[key]; found {
+			measures = append(measures, map[string]interface{}{"metric": key, "value": val})
+		}
+		if val, found := p.NewMeasures[key]; found {
+			measures = append(measures, map[string]interface{}{"metric": key, "period": map[string]interface{}{"value": val}})
+		}
+	}
+	res := map[string]interface{}{
+		"component": map[string]interface{}{"key": p.Key, "name": p.Name, "qualifier": p.qualifier(), "measures": measures},
+	}
+	if strings.Contains(rq.URL.Query().Get("additionalFields"), "periods") {
+		res["period"] = &sonar.Period{Mode: "previous_version", Date: sonar.Date(p.AnalysisDate)}
+	}
+	if strings.Contains(rq.URL.Query().Get("additionalFields"), "metrics") {
+		res["metrics"] = s.definitions(strings.Split(rq.URL.Query().Get("metricKeys"), ","))
+	}
+	writeJSON(w, res)
+}
+
+func (s *Server) serverInfo(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, &sonar.ServerInfo{Version: "9.9.0", Edition: "community"})
+}
+
+func (s *Server) projectStatus(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("projectKey"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project '%s' not found", rq.URL.Query().Get("projectKey")))
+		return
+	}
+	status := &sonar.ProjectStatus{Status: p.GateStatus, Conditions: p.GateConditions}
+	if status.Status == "" {
+		status.Status = "NONE"
+	}
+	writeJSON(w, map[string]interface{}{"projectStatus": status})
+}
+
+func (s *Server) projectQualityGate(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("project"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project '%s' not found", rq.URL.Query().Get("project")))
+		return
+	}
+	gate := p.QualityGate
+	if gate == nil {
+		gate = &sonar.QualityGate{ID: "1", Name: "Sonar way", Default: true}
+	}
+	writeJSON(w, map[string]interface{}{"qualityGate": gate})
+}
+
+func (s *Server) projectLinks(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("projectKey"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project '%s' not found", rq.URL.Query().Get("projectKey")))
+		return
+	}
+	writeJSON(w, &sonar.ProjectLinks{Links: p.Links})
+}
+
+func (s *Server) pullRequests(w http.ResponseWriter, rq *http.Request) {
+	p, found := s.project(rq.URL.Query().Get("project"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project '%s' not found", rq.URL.Query().Get("project")))
+		return
+	}
+	writeJSON(w, &sonar.PullRequests{PullRequests: p.PullRequests})
+}
+
+func (s *Server) userTokens(w http.ResponseWriter, _ *http.Request) {
+	s.mut.RLock()
+	tokens := s.tokens
+	s.mut.RUnlock()
+	writeJSON(w, &sonar.UserTokens{Login: "admin", UserTokens: tokens})
+}
+
+// definitions returns definitions of metrics with provided keys
+func (s *Server) definitions(keys []string) []*sonar.Metric {
+	requested := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		requested[key] = struct{}{}
+	}
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	metrics := []*sonar.Metric{}
+	for _, m := range s.metrics {
+		if _, found := requested[m.Key]; found {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+func (s *Server) project(key string) (*Project, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	p, found := s.projects[key]
+	return p, found
+}
+
+// contains reports whether values contain the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// paging returns page index and size requested and bounds of the page
+func paging(rq *http.Request, total int) (page, size, from, to int) {
+	page, size = 1, defaultPageSize
+	if p, err := strconv.Atoi(rq.URL.Query().Get("p")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(rq.URL.Query().Get("ps")); err == nil && ps > 0 {
+		size = ps
+	}
+	from, to = (page-1)*size, page*size
+	if from > total {
+		from = total
+	}
+	if to > total {
+		to = total
+	}
+	return page, size, from, to
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Print(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"msg": msg}}}); err != nil {
+		log.Print(err)
+	}
+}
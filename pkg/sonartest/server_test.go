@@ -0,0 +1,161 @@
+package sonartest_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/exporter"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonar"
+	"github.com/avarabyeu/sonarqube-prometheus-exporter/pkg/sonartest"
+)
+
+// newServer starts server of a project with issues, quality gate, links and pull requests along with a portfolio
+func newServer(t *testing.T) *sonartest.Server {
+	now := time.Now()
+	srv := sonartest.NewServer(sonartest.DefaultMetrics(),
+		&sonartest.Project{
+			Key:          "shop",
+			Name:         "Shop",
+			Tags:         []string{"team#payments"},
+			AnalysisDate: now,
+			Measures:     map[string]string{"bugs": "3", "coverage": "81.5", "alert_status": "ERROR"},
+			Issues: []*sonartest.Issue{
+				{Key: "1", Rule: "go:S1", Severity: "MAJOR", Type: "BUG", File: "main.go", Line: 10, Created: now.AddDate(0, 0, -1)},
+				{Key: "2", Rule: "go:S1", Severity: "MINOR", Type: "CODE_SMELL", File: "main.go", Created: now.AddDate(0, 0, -40)},
+				{Key: "3", Rule: "go:S2", Severity: "MAJOR", Type: "BUG", File: "api.go", Created: now.AddDate(0, 0, -5)},
+				{Key: "4", Rule: "go:S3", Severity: "INFO", Type: "CODE_SMELL", Resolution: "FALSE-POSITIVE", Created: now},
+			},
+			GateStatus: "ERROR",
+			GateConditions: []*sonar.GateCondition{
+				{Status: "ERROR", MetricKey: "coverage", Comparator: "LT", ErrorThreshold: "90", ActualValue: "81.5"},
+			},
+			QualityGate: &sonar.QualityGate{ID: "2", Name: "Strict"},
+			Links:       []*sonar.ProjectLink{{Type: "scm", URL: "https://git.example.com/shop"}},
+			PullRequests: []*sonar.PullRequest{
+				{Key: "7", Branch: "feature", Base: "main", Status: &sonar.PullRequestStatus{QualityGateStatus: "ERROR"}},
+			},
+		},
+		&sonartest.Project{
+			Key:       "all",
+			Name:      "All",
+			Qualifier: "VW",
+			Measures:  map[string]string{"reliability_rating": "2", "reliability_rating_distribution": "1=0;2=1;3=1"},
+		})
+	srv.SetUserTokens(&sonar.UserToken{Name: "exporter", ExpirationDate: sonar.Date(now.AddDate(0, 0, 10))})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServerAPI(t *testing.T) {
+	client := sonar.NewClient(newServer(t).URL(), "", "")
+
+	projects, err := client.GetComponents()
+	if err != nil || len(projects) != 1 || projects[0].Key != "shop" {
+		t.Errorf("projects %v, error %v, want shop only", projects, err)
+	}
+	portfolios, err := client.GetPortfolios()
+	if err != nil || len(portfolios) != 1 || portfolios[0].Key != "all" {
+		t.Errorf("portfolios %v, error %v, want all only", portfolios, err)
+	}
+
+	open, err := client.SearchIssues(url.Values{"componentKeys": {"shop"}, "resolved": {"false"}, "facets": {"rules"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open.Count() != 3 {
+		t.Errorf("%d open issues, want 3", open.Count())
+	}
+	if rules := open.Facet("rules"); len(rules) != 2 || rules[0].Val != "go:S1" || rules[0].Count != 2 {
+		t.Errorf("unexpected rules facet %v", rules)
+	}
+	recent, err := client.SearchIssues(url.Values{"componentKeys": {"shop"}, "resolved": {"false"},
+		"createdAfter": {sonar.Date(time.Now().AddDate(0, 0, -7)).String()}})
+	if err != nil || recent.Count() != 2 {
+		t.Errorf("%d issues created within a week, error %v, want 2", recent.Count(), err)
+	}
+	issues, err := client.GetOpenIssues("shop")
+	if err != nil || len(issues) != 3 || issues[0].Component != "shop:main.go" {
+		t.Errorf("open issues %v, error %v", issues, err)
+	}
+
+	status, err := client.GetProjectStatus("shop")
+	if err != nil || status.Status != "ERROR" || len(status.Conditions) != 1 {
+		t.Errorf("quality gate status %v, error %v", status, err)
+	}
+	gate, err := client.GetProjectQualityGate("shop")
+	if err != nil || gate.Name != "Strict" {
+		t.Errorf("quality gate %v, error %v", gate, err)
+	}
+	links, err := client.GetProjectLinks("shop")
+	if err != nil || len(links) != 1 || links[0].Type != "scm" {
+		t.Errorf("links %v, error %v", links, err)
+	}
+	pulls, err := client.GetPullRequests("shop")
+	if err != nil || len(pulls) != 1 || pulls[0].Status.QualityGateStatus != "ERROR" {
+		t.Errorf("pull requests %v, error %v", pulls, err)
+	}
+	tokens, err := client.GetUserTokens()
+	if err != nil || len(tokens) != 1 || tokens[0].Name != "exporter" {
+		t.Errorf("tokens %v, error %v", tokens, err)
+	}
+	info, err := client.GetServerInfo()
+	if err != nil || info.Version == "" {
+		t.Errorf("server info %v, error %v", info, err)
+	}
+
+	if _, err := client.GetProjectLinks("missing"); !errors.Is(err, sonar.ErrNotFound) {
+		t.Errorf("links of missing project failed with %v, want not found", err)
+	}
+}
+
+func TestCollectorAgainstServer(t *testing.T) {
+	client := sonar.NewClient(newServer(t).URL(), "", "")
+	exp := exporter.NewPrometheusExporter(exporter.ExporterConfig{LabelSeparator: "#"})
+	collector := exporter.NewCollector(client, exp, exporter.Config{
+		ProjectLinks:   true,
+		GateConditions: true,
+		GateInfo:       true,
+		PullRequests:   true,
+		Portfolios:     true,
+		CheckTokens:    true,
+		TokenName:      "exporter",
+		TopRules:       1,
+	})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exp, collector)
+	if err := collector.RunOnce(); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			gathered[family.GetName()] += m.GetGauge().GetValue()
+		}
+	}
+	for name, want := range map[string]float64{
+		"sonar_bugs":                               3,
+		"sonar_rule_issues":                        2,
+		"sonar_project_links":                      1,
+		"sonar_quality_gate_threshold":             90,
+		"sonar_project_quality_gate_info":          1,
+		"sonar_pull_requests_failing_quality_gate": 1,
+		"sonar_portfolio_rating":                   2,
+		"sonar_portfolio_worst_project_rating":     3,
+	} {
+		if got, found := gathered[name]; !found || got != want {
+			t.Errorf("%s is %v, want %v", name, got, want)
+		}
+	}
+	if _, found := gathered["sonar_exporter_token_expires_in_seconds"]; !found {
+		t.Error("token expiry is not exported")
+	}
+}